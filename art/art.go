@@ -0,0 +1,10 @@
+// Package art holds the GOOEY artwork that is served by the default layout
+package art
+
+import _ "embed"
+
+//go:embed favicon-16x16.png
+var Favicon16 []byte
+
+//go:embed favicon-32x32.png
+var Favicon32 []byte
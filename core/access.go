@@ -0,0 +1,89 @@
+package core
+
+import (
+	"errors"
+	"io"
+
+	"github.com/finite8/gooey/register"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrNotPermitted is returned when a user attempts to use a component they do not have access to
+var ErrNotPermitted = errors.New("you do not have permission to do this")
+
+// Access controls who is able to see and use a component. Authorizers are evaluated against the page being rendered.
+// A nil Access allows anyone that can see the page to use the component.
+type Access struct {
+	view []register.PageAuthorizer
+	use  []register.PageAuthorizer
+}
+
+func NewAccess() *Access {
+	return &Access{}
+}
+
+// WithViewRoles only shows the component to users that hold one of the roles. Anyone else sees a placeholder.
+func (a *Access) WithViewRoles(roles ...string) *Access {
+	return a.WithViewAuthorizer(register.RoleAuthorizer(roles...))
+}
+
+// WithUseRoles only allows users that hold one of the roles to use the component (i.e: submit a form). Anyone else sees it read-only.
+func (a *Access) WithUseRoles(roles ...string) *Access {
+	return a.WithUseAuthorizer(register.RoleAuthorizer(roles...))
+}
+
+func (a *Access) WithViewAuthorizer(pa ...register.PageAuthorizer) *Access {
+	a.view = append(a.view, pa...)
+	return a
+}
+
+func (a *Access) WithUseAuthorizer(pa ...register.PageAuthorizer) *Access {
+	a.use = append(a.use, pa...)
+	return a
+}
+
+// CanView returns true if the user is allowed to see the component
+func (a *Access) CanView(ctx register.PageContext) bool {
+	if a == nil {
+		return true
+	}
+	return evaluateAuthorizers(ctx, a.view)
+}
+
+// CanUse returns true if the user is allowed to interact with the component. Users that cannot view a component cannot use it either.
+func (a *Access) CanUse(ctx register.PageContext) bool {
+	if a == nil {
+		return true
+	}
+	return a.CanView(ctx) && evaluateAuthorizers(ctx, a.use)
+}
+
+func evaluateAuthorizers(ctx register.PageContext, authorizers []register.PageAuthorizer) bool {
+	for _, pa := range authorizers {
+		if !pa.IsAuthorized(ctx.CurrentPage(), ctx).Authorized() {
+			return false
+		}
+	}
+	return true
+}
+
+type accessControlled interface {
+	getAccess() *Access
+}
+
+// Write renders the component, or a placeholder if the user is not allowed to see it. If the component panics, an error is written in
+// its place so the rest of the page can still be rendered.
+func Write(ctx register.PageContext, w PageWriter, c Component) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err := register.Recovered(rec)
+			logrus.WithError(err).WithField("stack", string(err.Stack)).Errorf("%T panicked", c)
+			WriteComponentError(ctx, c, errors.New(register.ErrorMessage(ctx, err)), w)
+		}
+	}()
+	if ac, ok := c.(accessControlled); ok && !ac.getAccess().CanView(ctx) {
+		io.WriteString(w, RestrictedTemplate)
+		return
+	}
+	c.Write(ctx, w)
+}
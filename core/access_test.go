@@ -0,0 +1,95 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+type roleHeaderAuthenticator struct {
+}
+
+func (ra *roleHeaderAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*register.User, error) {
+	if roles := r.Header.Get("X-Roles"); roles != "" {
+		return &register.User{Id: "u", Roles: strings.Split(roles, ",")}, nil
+	}
+	return nil, nil
+}
+
+var csrfInput = regexp.MustCompile(`name="` + register.CSRFFieldName + `" value="([^"]+)"`)
+
+func TestFormAccess(t *testing.T) {
+	type Restart struct {
+		Worker string
+	}
+	restarted := ""
+	form := MustNewForm(func(register.PageContext) Restart { return Restart{} }).
+		WithSubmitHandler(func(ctx register.PageContext, v Restart) { restarted = v.Worker }).
+		WithAccess(NewAccess().WithViewRoles("viewer", "operator").WithUseRoles("operator"))
+	page := (&ContainerPage{}).WithName("workers").WithComponent(form)
+
+	site := register.NewSite()
+	site.RegisterPage(nil, register.RootPageId, page)
+	site.AddAuthenticator(&roleHeaderAuthenticator{})
+	h := site.Handler()
+
+	// the session and csrf token from the forms the browser has been shown
+	var cookies []*http.Cookie
+	token := ""
+	request := func(method, roles string) (int, string) {
+		var r *http.Request
+		if method == http.MethodPost {
+			r = httptest.NewRequest(method, "/", strings.NewReader(url.Values{"Worker": {"w1"}, register.CSRFFieldName: {token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			r = httptest.NewRequest(method, "/", nil)
+		}
+		r.Header.Set("X-Roles", roles)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		body, _ := io.ReadAll(rec.Result().Body)
+		if m := csrfInput.FindStringSubmatch(string(body)); m != nil {
+			token = m[1]
+		}
+		return rec.Code, string(body)
+	}
+
+	// the user is identified by a header rather than the session cookie, which a browser can't be made to send, so no token is needed
+	code, _ := request(http.MethodPost, "operator")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "w1", restarted)
+	restarted = ""
+
+	_, body := request(http.MethodGet, "guest")
+	assert.Contains(t, body, "GOOEY_restricted")
+	assert.NotContains(t, body, "<form")
+
+	_, body = request(http.MethodGet, "viewer")
+	assert.Contains(t, body, "<fieldset disabled>")
+	assert.NotContains(t, body, `type="submit"`)
+
+	_, body = request(http.MethodGet, "operator")
+	assert.NotContains(t, body, "disabled")
+	assert.Contains(t, body, `type="submit"`)
+
+	_, body = request(http.MethodPost, "viewer")
+	assert.Contains(t, body, ErrNotPermitted.Error())
+	assert.Empty(t, restarted)
+
+	code, _ = request(http.MethodPost, "operator")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "w1", restarted)
+}
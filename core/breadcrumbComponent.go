@@ -0,0 +1,38 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"github.com/finite8/gooey/register"
+)
+
+// BreadcrumbComponent shows the pages from the root of the site to the current page. Pages with path parameters show the values
+// of the current request.
+type BreadcrumbComponent struct {
+	ComponentBase
+}
+
+func NewBreadcrumbComponent() *BreadcrumbComponent {
+	return &BreadcrumbComponent{}
+}
+
+func (bc *BreadcrumbComponent) Write(ctx register.PageContext, w PageWriter) {
+	crumbs := ctx.Breadcrumbs()
+	io.WriteString(w, `<nav aria-label="breadcrumb"><ol class="breadcrumb">`)
+	for ix, c := range crumbs {
+		// titles can contain values from the url, so they must be escaped
+		title := html.EscapeString(c.Title())
+		if ix == len(crumbs)-1 {
+			io.WriteString(w, fmt.Sprintf(`<li class="breadcrumb-item active" aria-current="page">%s</li>`, title))
+		} else {
+			io.WriteString(w, fmt.Sprintf(`<li class="breadcrumb-item"><a href="%s">%s</a></li>`, html.EscapeString(c.Url().String()), title))
+		}
+	}
+	io.WriteString(w, `</ol></nav>`)
+}
+
+func (bc *BreadcrumbComponent) OnRegister(ctx register.Registerer) {
+
+}
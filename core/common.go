@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"html/template"
 	"io"
 	"net/http"
 	"reflect"
@@ -23,12 +24,29 @@ type PostableComponent interface {
 	HandlePost(register.PageContext, *http.Request) PostHandlerResult
 }
 
+// PostTargetFieldName is the hidden field that says which component a POST was sent to
+const PostTargetFieldName = "GOOEY_target"
+
+// A PostableComponent that is also a PostTarget is only given the POSTs that were sent to it, so several forms (or action buttons) can
+// live on the same page. POSTs that don't name a target are still given to every component.
+type PostTarget interface {
+	PostTargetId() string
+}
+
+// PostTargetField returns the hidden input that sends a POST to the component with the id. It has to be inside the form.
+func PostTargetField(id string) template.HTML {
+	return template.HTML(`<input type="hidden" name="` + PostTargetFieldName + `" value="` + register.EscapeAttribute(id) + `">`)
+}
+
 type PostHandlerResult struct {
 	// set to true if the post event has been handled. At least one component must handle the post for it to be accepted
 	IsHandled bool
 	// set to true if calling HandlePost should stop.
 	HaltProcessing bool
 	Error          error
+	// set to true if the post was handled but the data was refused (i.e: it failed validation). The page is shown again rather than
+	// redirected, so the problems can be shown with it.
+	Invalid bool
 }
 
 type Renderable interface {
@@ -53,31 +71,29 @@ func WriteElements(ctx register.PageContext, prefix, suffix string, w PageWriter
 
 func WriteElement(ctx register.PageContext, w PageWriter, val interface{}) {
 	switch v := val.(type) {
+	case Component:
+		Write(ctx, w, v)
 	case Renderable:
 		v.Write(ctx, w)
 	case []Renderable:
 		for _, item := range v {
 			item.Write(ctx, w)
 		}
-	case Component:
-		v.Write(ctx, w)
+	case register.SafeHTML, template.HTML:
+		io.WriteString(w, register.EscapeText(v))
 	default:
 		text := fmt.Sprintf("%v", v)
 		NewTextPrimitve(text).Write(ctx, w)
 	}
 }
 
-func Write(ctx register.PageContext, w PageWriter, c Component) {
-
-}
-
 func createTagAttribs(vals ...string) string {
 	var attrs []string
 	for ix := 0; ix < len(vals); ix += 2 {
 		attr := vals[ix]
 		val := vals[ix+1]
 		if strings.TrimSpace(val) != "" {
-			attrs = append(attrs, fmt.Sprintf("%v=\"%v\"", attr, val))
+			attrs = append(attrs, fmt.Sprintf("%v=\"%v\"", attr, register.EscapeAttribute(val)))
 		}
 	}
 	if len(attrs) > 0 {
@@ -88,8 +104,13 @@ func createTagAttribs(vals ...string) string {
 
 type PageWriter interface {
 	io.Writer
-	// GetScriptWriter returns a writer to allow you to write into a custom script block.
+	// GetScriptWriter returns a writer to allow you to write into a custom script block. Blocks are shared by everything on the page
+	// and written at the end of the body.
 	GetScriptWriter(scriptSectionName, typename string) ScriptWriter
+	// GetStyleWriter returns a writer for a named block of css, shared the same way as script blocks.
+	GetStyleWriter(styleSectionName string) ScriptWriter
+	// RequireScript declares an external script the component depends on. It is loaded once, before any of the script blocks.
+	RequireScript(source interface{}) *register.ScriptElement
 	//RegisterComponent(c Component) RegisteredInfo
 	WriteElement(register.PageContext, interface{})
 }
@@ -97,6 +118,8 @@ type PageWriter interface {
 type ScriptWriter interface {
 	io.Writer
 	io.StringWriter
+	// Once writes the script only the first time the key is used in the block (i.e: setup code shared by every copy of a component)
+	Once(key, script string)
 }
 
 type RegisteredInfo struct {
@@ -112,27 +135,21 @@ type TagPrimitive struct {
 
 func (tp *TagPrimitive) Write(ctx register.PageContext, w PageWriter) {
 	w.Write([]byte(fmt.Sprintf("<%s", tp.Name)))
-	if tp.Attributes != nil && len(tp.Attributes) > 0 {
+	if len(tp.Attributes) > 0 {
+		attrs := make(map[string]interface{}, len(tp.Attributes))
 		for k, v := range tp.Attributes {
-			w.Write([]byte(" " + k))
-			if v != nil {
-
-				var textToWrite string
-				switch aVal := v.(type) {
-				case string:
-					textToWrite = fmt.Sprintf(`"%s"`, aVal)
-				case *string:
-					textToWrite = fmt.Sprintf(`"%s"`, *aVal)
-				default:
-					rv := reflect.ValueOf(aVal)
-					for rv.Kind() == reflect.Pointer {
-						rv = rv.Elem()
-					}
-					textToWrite = fmt.Sprintf("%v", rv.Interface())
-				}
-				w.Write([]byte(fmt.Sprintf("=%s", textToWrite)))
+			if v == nil {
+				// an attribute without a value (i.e: disabled)
+				attrs[k] = true
+				continue
+			}
+			rv := reflect.ValueOf(v)
+			for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+				rv = rv.Elem()
 			}
+			attrs[k] = rv.Interface()
 		}
+		w.Write([]byte(register.MapToAttributes(attrs)))
 	}
 	if tp.Unpaired {
 		// there cannot be inner text for this
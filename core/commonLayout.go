@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"html/template"
 	"net/http"
 	"strings"
@@ -17,6 +18,7 @@ const (
 	trailingTemplate = `</div>
 <div id="GOOEY_footer" class="GOOEY GOOEY_footer">{{.Footer}}</div>
 `
+	flashTemplate = `{{range .}}<div class="GOOEY_flash GOOEY_flash_{{.Level}} alert alert-{{alertClass .Level}}" role="alert">{{.Message}}</div>{{end}}`
 )
 
 type (
@@ -32,7 +34,7 @@ type (
 
 // CommonLayout provides a flexible off-the-shelf layout for common site layouts. This gives you sections for:
 // - Navigation Menus
-// - Status
+// - Status (flash messages are shown here, above the Status component)
 // - Headers
 // - Footers
 type CommonLayout struct {
@@ -40,13 +42,24 @@ type CommonLayout struct {
 	Status Component
 	Header Component
 	Footer Component
-	t      *template.Template
+	// Favicons are added to the head of every page. These default to the GOOEY icons
+	Favicons []Favicon
+	t        *template.Template
+}
+
+// Favicon is an icon for the site. Source is anything that resolves to a url, such as a file or a url string
+type Favicon struct {
+	Source interface{}
+	Sizes  string
 }
 
 func NewCommonLayout() *CommonLayout {
-	cl := &CommonLayout{}
+	cl := &CommonLayout{
+		Favicons: append([]Favicon(nil), defaultFavicons...),
+	}
 	t := template.Must(template.New("leading").Parse(leadingTemplate))
 	t = template.Must(t.New("trailing").Parse(trailingTemplate))
+	t = template.Must(t.New("flash").Funcs(template.FuncMap{"alertClass": alertClass}).Parse(flashTemplate))
 	cl.t = t
 
 	return cl
@@ -56,18 +69,58 @@ func NewCommonLayout() *CommonLayout {
 var _ register.PageLayout = (*CommonLayout)(nil)
 
 func (cl *CommonLayout) Render(ctx register.PageContext, w http.ResponseWriter, r *http.Request, pageRenderer func(ctx register.PageContext, w http.ResponseWriter, r *http.Request)) {
-
+	var page *deferredWriter
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		// the page has to be handled first, so anything it flashes (or a redirect) is known before the status is written
+		page = &deferredWriter{ResponseWriter: w}
+		pageRenderer(ctx, page, r)
+		if w.Header().Get("Location") != "" {
+			// the flashes are left for the page the browser is sent to
+			return
+		}
+	}
 	cl.t.Lookup("leading").Execute(w, leadingData{
 		Header: GetComponentHTML(ctx, cl.Header),
-		Status: GetComponentHTML(ctx, cl.Status),
+		Status: cl.flashHTML(ctx) + GetComponentHTML(ctx, cl.Status),
 		Nav:    GetComponentHTML(ctx, cl.Nav),
 	})
-	pageRenderer(ctx, w, r)
+	if page != nil {
+		w.Write(page.buf.Bytes())
+	} else {
+		pageRenderer(ctx, w, r)
+	}
 	cl.t.Lookup("trailing").Execute(w, trailingData{
 		Footer: GetComponentHTML(ctx, cl.Footer),
 	})
 }
 
+func (cl *CommonLayout) flashHTML(ctx register.PageContext) template.HTML {
+	flashes := register.TakeFlashes(ctx)
+	if len(flashes) == 0 {
+		return ""
+	}
+	sb := &strings.Builder{}
+	cl.t.Lookup("flash").Execute(sb, flashes)
+	return template.HTML(sb.String())
+}
+
+func alertClass(l register.FlashLevel) string {
+	if l == register.FlashError {
+		return "danger"
+	}
+	return string(l)
+}
+
+// deferredWriter holds the body of the page back so it can be written after the parts of the layout that come before it
+type deferredWriter struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (dw *deferredWriter) Write(b []byte) (int, error) {
+	return dw.buf.Write(b)
+}
+
 func (cl *CommonLayout) OnHandlerAdded(reg register.Registerer) {
 	for _, c := range []Component{cl.Nav, cl.Status, cl.Header, cl.Footer} {
 		if c != nil {
@@ -77,7 +130,12 @@ func (cl *CommonLayout) OnHandlerAdded(reg register.Registerer) {
 }
 
 func (cl *CommonLayout) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
-	return b
+	return b.WithMetaHandling(func(m *register.PageHead) *register.PageHead {
+		for _, f := range cl.Favicons {
+			m.AddFavicon(f.Source, f.Sizes)
+		}
+		return m
+	})
 }
 func GetComponentHTML(ctx register.PageContext, c Component) template.HTML {
 	if c == nil {
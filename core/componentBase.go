@@ -5,10 +5,27 @@ import "github.com/finite8/gooey/register"
 // all components should share this
 type ComponentBase struct {
 	*register.AttibutingElement
-	Style     Styling
+	Style Styling
+	// Who can see and use the component. If nil, anyone that can see the page can use it.
+	Access *Access
+	// Identifies the component on its page. This is the key of its data when the page is requested as JSON, and the target a form posts
+	// to. It is not written as the id of an element. If not set, one is assigned when the page is registered.
+	Id        string
 	currstate renderstate
 }
 
+func (cb *ComponentBase) getId() string {
+	return cb.Id
+}
+
+func (cb *ComponentBase) setId(id string) {
+	cb.Id = id
+}
+
+func (cb *ComponentBase) getAccess() *Access {
+	return cb.Access
+}
+
 func (cb *ComponentBase) setRenderState(s renderstate) {
 	cb.currstate = s
 }
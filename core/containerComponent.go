@@ -26,7 +26,7 @@ type basicRenderer struct {
 func (br *basicRenderer) RenderContainer(cc *ContainerComponent, ctx register.PageContext, w PageWriter) {
 	for _, child := range cc.children {
 		io.WriteString(w, `<div>`)
-		child.Write(ctx, w)
+		Write(ctx, w, child)
 		io.WriteString(w, `</div>`)
 	}
 }
@@ -77,11 +77,11 @@ func (cr *CardRenderer) RenderContainer(cc *ContainerComponent, ctx register.Pag
 	{
 		io.WriteString(w, `<div class="card-body">`)
 		if cr.Header != "" {
-			io.WriteString(w, fmt.Sprintf(`<h4 class="card-title">%s</h4>`, cr.Header))
+			io.WriteString(w, fmt.Sprintf(`<h4 class="card-title">%s</h4>`, register.EscapeText(cr.Header)))
 		}
 		for _, child := range cc.children {
 			io.WriteString(w, "<div>")
-			child.Write(ctx, w)
+			Write(ctx, w, child)
 			io.WriteString(w, `</div>`)
 		}
 		io.WriteString(w, `</div>`)
@@ -109,6 +109,21 @@ func (cc *ContainerComponent) WithComponent(c Component) *ContainerComponent {
 	return cc
 }
 
+// GetChildren returns the components in the container, and any they contain
+func (cc *ContainerComponent) GetChildren() (ret []Component) {
+	for _, child := range cc.children {
+		ret = append(ret, child)
+		if p, ok := child.(ParentableComponent); ok {
+			ret = append(ret, p.GetChildren()...)
+		}
+	}
+	return
+}
+
+func (cc *ContainerComponent) directChildren() []Component {
+	return cc.children
+}
+
 func (cc *ContainerComponent) WithRenderables(r ...Renderable) {
 	//TODO
 }
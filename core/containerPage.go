@@ -7,7 +7,6 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"strings"
 
 	"github.com/finite8/gooey/register"
 )
@@ -23,6 +22,7 @@ type ContainerPage struct {
 	name               string
 	components         *LayoutComponent
 	postableComponents []PostableComponent
+	redirectAfterPost  bool
 }
 
 func (cp *ContainerPage) WithName(n string) *ContainerPage {
@@ -30,6 +30,27 @@ func (cp *ContainerPage) WithName(n string) *ContainerPage {
 	return cp
 }
 
+// WithRedirectAfterPost redirects the browser back to the page once a POST has been handled, rather than showing the page in the
+// response (Post/Redirect/Get). Refreshing the page then doesn't submit the form again. Posts that fail validation are still shown in the
+// response so the problems can be seen. Use flash messages (i.e: FormComponent.WithMessages) to tell the user what happened.
+// The redirect can only be sent if nothing has been sent before it, so the page is always rendered buffered (see register.RenderBuffered).
+func (cp *ContainerPage) WithRedirectAfterPost(redirect bool) *ContainerPage {
+	cp.redirectAfterPost = redirect
+	return cp
+}
+
+// QueryBehaviour buffers the page if it redirects after a post, whatever the render mode of the site. The CSRF token of any forms is
+// created here, before the page starts to be sent, so a new visitor is given their session cookie.
+func (cp *ContainerPage) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
+	if len(cp.postableComponents) > 0 {
+		register.CSRFToken(ctx)
+	}
+	if cp.redirectAfterPost {
+		return b.WithRenderMode(register.RenderBuffered)
+	}
+	return b
+}
+
 func (cp *ContainerPage) WithColumns(colCount int) *ContainerPage {
 	if cp.components == nil {
 		cp.components = NewLayoutComponent(colCount)
@@ -60,19 +81,35 @@ func (cp *ContainerPage) Handler(ctx register.PageContext, w http.ResponseWriter
 	case http.MethodPost:
 		// we now need to go through all of our post handlers to see if something needs to be done.
 		isHandled := false
-		for _, h := range cp.postableComponents {
+		notPermitted := false
+		invalid := false
+		for _, h := range cp.postTargets(r) {
 			pr := h.HandlePost(ctx, r)
 			if pr.IsHandled {
 				isHandled = true
 			}
+			if pr.Invalid {
+				invalid = true
+			}
+			if errors.Is(pr.Error, ErrNotPermitted) {
+				notPermitted = true
+			}
 			if pr.HaltProcessing {
 				break
 			}
 		}
-		if isHandled {
+		if isHandled && cp.redirectAfterPost && !invalid {
+			u := ctx.GetPageUrl(ctx.CurrentPage())
+			u.RawQuery = r.URL.RawQuery
+			http.Redirect(w, r, u.String(), http.StatusSeeOther)
+		} else if isHandled {
 			// the post has been handled by a component. We can continue rendering
 			pw = newPageWriter(ctx, w)
 			cp.components.Write(ctx, pw)
+		} else if notPermitted {
+			w.WriteHeader(http.StatusForbidden)
+			WriteComponentError(ctx, nil, ErrNotPermitted, w)
+			return nil
 		} else {
 			WriteComponentError(ctx, nil, errors.New("the POST data wa either invalid or not handled by any component"), w)
 			w.WriteHeader(400)
@@ -87,9 +124,35 @@ func (cp *ContainerPage) Handler(ctx register.PageContext, w http.ResponseWriter
 
 }
 
+// postTargets returns the components the POST should be given to. If it names its target, that is the only one.
+func (cp *ContainerPage) postTargets(r *http.Request) []PostableComponent {
+	id := r.FormValue(PostTargetFieldName)
+	if id == "" {
+		return cp.postableComponents
+	}
+	for _, pc := range cp.postableComponents {
+		if pt, ok := pc.(PostTarget); ok && pt.PostTargetId() == id {
+			return []PostableComponent{pc}
+		}
+	}
+	return nil
+}
+
+// Data returns the data of each component on the page, keyed by the id of the component. Components the user cannot see are left out.
+func (cp *ContainerPage) Data(ctx register.PageContext) (interface{}, error) {
+	if cp.components == nil {
+		return map[string]interface{}{}, nil
+	}
+	return componentData(ctx, cp.components.directChildren())
+}
+
 func (cp *ContainerPage) OnHandlerAdded(parentPage register.Registerer) {
+	if cp.components == nil {
+		return
+	}
 	// check to see if any of our components need to do some fancy registration
 	cp.components.OnRegister(parentPage)
+	assignIds(cp.components.GetChildren())
 	var postable []PostableComponent
 	for _, comp := range cp.components.GetChildren() {
 		switch item := comp.(type) {
@@ -119,48 +182,30 @@ func newPageWriter(ctx register.PageContext, w io.Writer) *pageWriter {
 	}
 }
 
-type scriptBlock struct {
-	name       string
-	scriptType string
-	data       strings.Builder
-}
-
-func (sb *scriptBlock) Write(p []byte) (n int, err error) {
-	return sb.data.Write(p)
-}
-
-func (sb *scriptBlock) WriteString(s string) (n int, err error) {
-	return sb.data.WriteString(s)
-}
-
 type pageWriter struct {
 	io.Writer
-	ctx     register.PageContext
-	scripts []*scriptBlock
+	ctx register.PageContext
 	//	regList map[Component]RegisteredInfo
 }
 
+// Finalize writes the scripts collected so far. The site does this at the end of the body, so this is only needed when a page is
+// rendered outside of a site.
 func (pw *pageWriter) Finalize() {
-	for _, sb := range pw.scripts {
-
-		pw.Write([]byte(fmt.Sprintf(`<script type="%s">`, sb.scriptType) + "\n"))
-		pw.Write([]byte(sb.data.String()))
-		pw.Write([]byte("\n</script>\n"))
-	}
+	register.GetPageScripts(pw.ctx).Write(pw.ctx, pw)
 }
 
+// GetScriptWriter returns the script section for the whole page, so every component (and the layout) asking for the same section
+// writes into the same block.
 func (pw *pageWriter) GetScriptWriter(scriptSectionName, scriptType string) ScriptWriter {
-	for _, v := range pw.scripts {
-		if v.name == scriptSectionName && v.scriptType == scriptType {
-			return v
-		}
-	}
-	sw := &scriptBlock{
-		name:       scriptSectionName,
-		scriptType: scriptType,
-	}
-	pw.scripts = append(pw.scripts, sw)
-	return sw
+	return register.GetPageScripts(pw.ctx).Section(scriptSectionName, scriptType)
+}
+
+func (pw *pageWriter) GetStyleWriter(styleSectionName string) ScriptWriter {
+	return register.GetPageScripts(pw.ctx).StyleSection(styleSectionName)
+}
+
+func (pw *pageWriter) RequireScript(source interface{}) *register.ScriptElement {
+	return register.GetPageScripts(pw.ctx).Require(source)
 }
 
 func (pw *pageWriter) WriteElement(ctx register.PageContext, val interface{}) {
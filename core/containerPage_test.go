@@ -0,0 +1,120 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+type scriptedComponent struct {
+	nilComponent
+	text string
+}
+
+func (sc *scriptedComponent) Write(ctx register.PageContext, w PageWriter) {
+	w.RequireScript("/chart.js").Defer()
+	w.GetScriptWriter("charts", "").Once("setup", "setupCharts();")
+	w.GetScriptWriter("charts", "").WriteString("draw('" + sc.text + "');\n")
+	w.GetScriptWriter("charts", "").WriteString("done();\n")
+	w.GetScriptWriter("charts", "module").WriteString("import '/charts.mjs';")
+	w.GetStyleWriter("charts").Once("chart", ".chart{width:100%}")
+	io.WriteString(w, sc.text)
+}
+
+func TestPageScripts(t *testing.T) {
+	page := (&ContainerPage{}).WithName("charts").
+		WithComponent(&scriptedComponent{text: "cpu"}).
+		WithComponent(&scriptedComponent{text: "memory"})
+	layout := NewCommonLayout()
+	layout.Footer = &scriptedComponent{text: "footer"}
+	site := register.NewSite()
+	site.SetLayout(layout)
+	site.RegisterPage(nil, register.RootPageId, page)
+
+	rec := httptest.NewRecorder()
+	site.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	assert.Equal(t, 1, strings.Count(body, `src="/chart.js"`))
+	assert.Equal(t, 1, strings.Count(body, "setupCharts();"))
+	assert.Equal(t, 1, strings.Count(body, ".chart{width:100%}"))
+	// repeated text is kept, and sections with the same name but a different type are written separately
+	assert.Equal(t, 3, strings.Count(body, "done();\n"))
+	assert.Equal(t, 1, strings.Count(body, `<script type="module"`))
+	for _, s := range []string{"cpu", "memory", "footer"} {
+		assert.Contains(t, body, "draw('"+s+"');")
+	}
+	// the scripts come after everything on the page, just before the body closes
+	scripts := strings.Index(body, "setupCharts();")
+	assert.Greater(t, scripts, strings.LastIndex(body, "footer</div>"))
+	assert.True(t, strings.HasSuffix(body, "</script>\n</body></html>"), body)
+	assert.Equal(t, 3, strings.Count(body, "<script"))
+}
+
+func TestRedirectAfterPost(t *testing.T) {
+	type Restart struct {
+		Worker string `gooey:"required"`
+	}
+	var restarted []string
+	form := MustNewForm(func(register.PageContext) Restart { return Restart{} }).
+		WithSubmitHandler(func(ctx register.PageContext, v Restart) { restarted = append(restarted, v.Worker) }).
+		WithMessages("Worker restarted", "Validation failed")
+	site := register.NewSite()
+	// the redirect has to work even if the site streams its pages
+	site.SetRenderMode(register.RenderStreaming)
+	site.RegisterPage(nil, register.RootPageId, (&ContainerPage{}).WithName("workers").WithRedirectAfterPost(true).WithComponent(form))
+	h := site.Handler()
+
+	var cookies []*http.Cookie
+	token := ""
+	request := func(method, worker string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if method == http.MethodPost {
+			r = httptest.NewRequest(method, "/?tab=2", strings.NewReader(url.Values{"Worker": {worker}, register.CSRFFieldName: {token}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			r = httptest.NewRequest(method, "/", nil)
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if c := rec.Result().Cookies(); len(c) > 0 {
+			cookies = c
+		}
+		if m := csrfInput.FindStringSubmatch(rec.Body.String()); m != nil {
+			token = m[1]
+		}
+		return rec
+	}
+	request(http.MethodGet, "")
+
+	rec := request(http.MethodPost, "w1")
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "http://example.com/?tab=2", rec.Header().Get("Location"))
+	assert.Empty(t, rec.Body.String())
+	assert.Equal(t, []string{"w1"}, restarted)
+
+	// the flash is shown once, on the page the browser was sent back to
+	rec = request(http.MethodGet, "")
+	assert.Contains(t, rec.Body.String(), "Worker restarted")
+	assert.Contains(t, rec.Body.String(), "GOOEY_flash_success")
+	rec = request(http.MethodGet, "")
+	assert.NotContains(t, rec.Body.String(), "Worker restarted")
+
+	// a post that fails validation is shown straight away, with its flash
+	rec = request(http.MethodPost, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Validation failed")
+	assert.Contains(t, rec.Body.String(), "is-invalid")
+	assert.Equal(t, []string{"w1"}, restarted)
+	rec = request(http.MethodGet, "")
+	assert.NotContains(t, rec.Body.String(), "Validation failed")
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/finite8/gooey/register"
+)
+
+// DataComponent is a component that shows data it gets when the page is rendered. When its page is requested as JSON, the data is
+// returned instead.
+type DataComponent interface {
+	Component
+	Data(ctx register.PageContext) (interface{}, error)
+}
+
+// parentComponent is a component that holds others, such as a container or layout
+type parentComponent interface {
+	directChildren() []Component
+}
+
+type identifiable interface {
+	getId() string
+	setId(id string)
+}
+
+// WithId sets the id of the component
+func WithId[C Component](c C, id string) C {
+	if i, ok := interface{}(c).(identifiable); ok {
+		i.setId(id)
+	}
+	return c
+}
+
+// assignIds gives any components without an id one based on their kind and position (i.e: table-1, table-2)
+func assignIds(components []Component) {
+	used := map[string]bool{}
+	for _, c := range components {
+		if i, ok := c.(identifiable); ok && i.getId() != "" {
+			used[i.getId()] = true
+		}
+	}
+	counts := map[string]int{}
+	for _, c := range components {
+		i, ok := c.(identifiable)
+		if !ok || i.getId() != "" {
+			continue
+		}
+		rt := reflect.TypeOf(c)
+		for rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		kind := strings.ToLower(strings.TrimSuffix(strings.SplitN(rt.Name(), "[", 2)[0], "Component"))
+		for {
+			counts[kind]++
+			if id := fmt.Sprintf("%s-%d", kind, counts[kind]); !used[id] {
+				i.setId(id)
+				used[id] = true
+				break
+			}
+		}
+	}
+}
+
+// componentData collects the data of the components the user can see, keyed by their id. The components inside containers are included,
+// unless the user can't see the container.
+func componentData(ctx register.PageContext, components []Component) (map[string]interface{}, error) {
+	ret := map[string]interface{}{}
+	if err := collectData(ctx, components, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func collectData(ctx register.PageContext, components []Component, ret map[string]interface{}) error {
+	for _, c := range components {
+		if ac, ok := c.(accessControlled); ok && !ac.getAccess().CanView(ctx) {
+			continue
+		}
+		if p, ok := c.(parentComponent); ok {
+			if err := collectData(ctx, p.directChildren(), ret); err != nil {
+				return err
+			}
+		}
+		dc, ok := c.(DataComponent)
+		if !ok {
+			continue
+		}
+		var id string
+		if i, ok := c.(identifiable); ok {
+			id = i.getId()
+		}
+		data, err := dc.Data(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the data of %s: %w", id, err)
+		}
+		ret[id] = data
+	}
+	return nil
+}
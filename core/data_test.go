@@ -0,0 +1,71 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPageData(t *testing.T) {
+	type worker struct {
+		Name string
+		Busy bool
+	}
+	workers := []worker{{Name: "w1", Busy: true}, {Name: "w2"}}
+	page := (&ContainerPage{}).WithName("workers").
+		WithComponent(WithId(NewTableComponent(func(register.PageContext) (interface{}, error) { return workers, nil }), "workers")).
+		WithComponent(NewObjectComponent(func(register.PageContext) (interface{}, error) { return map[string]int{"total": 2}, nil })).
+		WithComponent(NewListComponent(func(register.PageContext) (interface{}, error) { return []string{"a"}, nil })).
+		WithComponent(MustNewForm(func(register.PageContext) worker { return worker{} })).
+		WithComponent(NewContainerComponent().WithStyle(NewCardRenderer()).
+			WithComponent(NewTableComponent(func(register.PageContext) (interface{}, error) { return workers[:1], nil })))
+	secret := NewObjectComponent(func(register.PageContext) (interface{}, error) { return "secret", nil })
+	secret.(*ObjectComponent).Access = NewAccess().WithViewRoles("admin")
+	page.WithComponent(secret)
+
+	site := register.NewSite()
+	site.RegisterPage(nil, register.RootPageId, page)
+	h := site.Handler()
+
+	request := func(target, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := request("/", "application/json")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var data map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &data))
+	assert.JSONEq(t, `[{"Name":"w1","Busy":true},{"Name":"w2","Busy":false}]`, string(data["workers"]))
+	assert.JSONEq(t, `{"total":2}`, string(data["object-1"]))
+	assert.JSONEq(t, `["a"]`, string(data["list-1"]))
+	// components inside a container are found as well
+	assert.JSONEq(t, `[{"Name":"w1","Busy":true}]`, string(data["table-1"]))
+	assert.Len(t, data, 4)
+
+	rec = request("/?format=json", "text/html")
+	assert.Contains(t, rec.Body.String(), `"workers"`)
+
+	rec = request("/", "text/html,application/json;q=0.9")
+	assert.Contains(t, rec.Body.String(), "<html>")
+
+	failing := (&ContainerPage{}).WithName("failing").
+		WithComponent(NewListComponent(func(register.PageContext) (interface{}, error) { return nil, errors.New("down") }))
+	site.RegisterPage(page, "failing", failing)
+	rec = request("/failing?format=json", "")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	// internal errors are hidden, unless in dev mode
+	assert.NotContains(t, rec.Body.String(), "down")
+	site.SetDevMode(true)
+	rec = request("/failing?format=json", "")
+	assert.Contains(t, rec.Body.String(), "down")
+}
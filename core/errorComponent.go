@@ -0,0 +1,29 @@
+package core
+
+import (
+	"html/template"
+
+	"github.com/finite8/gooey/register"
+)
+
+var errorDetailsTemplate = template.Must(template.New("errordetails").Parse(`<div class="GOOEY GOOEY_error">
+{{if .StatusCode}}<h4>{{.StatusCode}}</h4>{{end}}<p>{{.Message}}</p>
+{{if .Detail}}<pre class="GOOEY_errordetail">{{.Detail}}</pre>{{end}}
+</div>`))
+
+// ErrorDetailsComponent shows the error the error page is rendering. The details of the error are only shown in dev mode.
+type ErrorDetailsComponent struct {
+	nilComponent
+}
+
+func NewErrorDetailsComponent() *ErrorDetailsComponent {
+	return &ErrorDetailsComponent{}
+}
+
+func (edc *ErrorDetailsComponent) Write(ctx register.PageContext, w PageWriter) {
+	details := register.GetErrorDetails(ctx)
+	if details == nil {
+		details = &register.ErrorDetails{Message: "An error occurred"}
+	}
+	errorDetailsTemplate.Execute(w, details)
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+type panicComponent struct {
+	nilComponent
+}
+
+func (pc *panicComponent) Write(ctx register.PageContext, w PageWriter) {
+	panic("component exploded")
+}
+
+func TestErrors(t *testing.T) {
+	page := (&ContainerPage{}).WithName("broken").
+		WithComponent(&panicComponent{}).
+		WithComponent(NewTextPrimitve("still here"))
+	site := register.NewSite()
+	site.CorePages().ErrorPage = createBaseErrorPage()
+	site.RegisterPage(nil, register.RootPageId, page)
+	h := site.Handler()
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body, _ := io.ReadAll(rec.Result().Body)
+		return rec.Code, string(body)
+	}
+
+	// a panicking component does not take the rest of the page down with it
+	code, body := get("/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "still here")
+	assert.Contains(t, body, "An unexpected error occurred")
+	assert.NotContains(t, body, "component exploded")
+
+	code, body = get("/missing")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body, "GOOEY_error")
+	assert.Contains(t, body, "/missing could not be found")
+
+	site.SetDevMode(true)
+	_, body = get("/")
+	assert.Contains(t, body, "component exploded")
+}
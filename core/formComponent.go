@@ -32,6 +32,9 @@ type FormComponent[T interface{}] struct {
 	defaultValueGetter func(register.PageContext) T
 	onFormSubmitted    func(register.PageContext, T)
 	KeepValues         bool
+	// flashed to the user after the form is submitted, or when it fails validation. Nothing is flashed if empty.
+	SuccessMessage string
+	FailureMessage string
 }
 
 type FormTemplate[T interface{}] struct {
@@ -49,6 +52,19 @@ func (ft *FormTemplate[T]) GetFieldRules() map[string]interface{} {
 	return ft.FieldRules
 }
 
+// WithAccess controls who can see and submit the form. Users that can see but not submit the form are shown it read-only.
+func (fc *FormComponent[T]) WithAccess(a *Access) *FormComponent[T] {
+	fc.Access = a
+	return fc
+}
+
+// WithMessages sets the flash messages shown to the user after the form is submitted (i.e: "Worker restarted") or fails validation
+func (fc *FormComponent[T]) WithMessages(success, failure string) *FormComponent[T] {
+	fc.SuccessMessage = success
+	fc.FailureMessage = failure
+	return fc
+}
+
 func (fc *FormComponent[T]) WithKeepValues(keep bool) *FormComponent[T] {
 	fc.KeepValues = keep
 	return fc
@@ -201,12 +217,24 @@ func (fc *FormComponent[T]) Write(ctx register.PageContext, w PageWriter) {
 	} else {
 		origValues = make(PathedMap[string])
 	}
+	readOnly := !fc.Access.CanUse(ctx)
 	io.WriteString(w, `<form action="" method="post">`)
+	io.WriteString(w, string(register.CSRFField(ctx)))
+	if id := fc.PostTargetId(); id != "" {
+		io.WriteString(w, string(PostTargetField(id)))
+	}
+	if readOnly {
+		io.WriteString(w, `<fieldset disabled>`)
+	}
 	{
 		formElements := buildFormElements(fc.fstruct, defaultValue, origValues, validationFailures)
 		w.WriteElement(ctx, formElements)
 	}
-	io.WriteString(w, `<button type="submit" class="btn btn-primary">Submit</button>`)
+	if readOnly {
+		io.WriteString(w, `</fieldset>`)
+	} else {
+		io.WriteString(w, `<button type="submit" class="btn btn-primary">Submit</button>`)
+	}
 	io.WriteString(w, `</form>`)
 }
 
@@ -270,6 +298,12 @@ func (pm PathedMap[T]) Set(path string, value T) {
 }
 
 func (fc *FormComponent[T]) HandlePost(ctx register.PageContext, r *http.Request) PostHandlerResult {
+	if !fc.Access.CanUse(ctx) {
+		// the form may not even be shown to the user, but the post is always refused
+		return PostHandlerResult{
+			Error: ErrNotPermitted,
+		}
+	}
 	if fc.onFormSubmitted != nil {
 		if len(r.Form) == 0 {
 			r.ParseForm()
@@ -282,7 +316,12 @@ func (fc *FormComponent[T]) HandlePost(ctx register.PageContext, r *http.Request
 		vmap := fstruct.GetMap()
 		validationErrors := make(PathedMap[string])
 		origValues := make(PathedMap[string])
-		for key, value := range r.Form {
+		// only the body is the form. The query string belongs to the page (and is kept when redirecting back to it)
+		for key, value := range r.PostForm {
+			if key == register.CSRFFieldName || key == PostTargetFieldName {
+				// checked before the post got here
+				continue
+			}
 			newVal := value[0]
 			tField, err := vmap.GetField(key)
 			if err != nil {
@@ -316,17 +355,30 @@ func (fc *FormComponent[T]) HandlePost(ctx register.PageContext, r *http.Request
 				ctx.RequestCache().SetValue(fmt.Sprintf("ORIG%s", fc.uniqueId), origValues)
 			}
 			fc.onFormSubmitted(ctx, outVal)
+			if fc.SuccessMessage != "" {
+				register.AddFlash(ctx, register.FlashSuccess, fc.SuccessMessage)
+			}
 		} else {
 			ctx.RequestCache().SetValue(fmt.Sprintf("ORIG%s", fc.uniqueId), origValues)
 			ctx.RequestCache().SetValue(fmt.Sprintf("VAL%s", fc.uniqueId), validationErrors)
+			if fc.FailureMessage != "" {
+				register.AddFlash(ctx, register.FlashError, fc.FailureMessage)
+			}
 		}
 		return PostHandlerResult{
 			IsHandled: true,
+			Invalid:   len(validationErrors) != 0,
 		}
 	}
 	return PostHandlerResult{}
 }
 
+// PostTargetId means the form is only given the POSTs from its own form. This is the id of the component, which is the same after a
+// restart (or on another server), so a form that is already open can still be posted.
+func (fc *FormComponent[T]) PostTargetId() string {
+	return fc.Id
+}
+
 func (fc *FormComponent[T]) OnRegister(rootCtx register.Registerer) {
 
 }
@@ -1,8 +1,15 @@
 package core
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/finite8/gooey/register"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -85,3 +92,51 @@ func TestReflectInValid(t *testing.T) {
 		_ = fs
 	}
 }
+
+func TestMultipleForms(t *testing.T) {
+	type Restart struct {
+		Worker string
+	}
+	type Scale struct {
+		Replicas int
+	}
+	var restarted []string
+	var scaled []int
+	restart := MustNewForm(func(register.PageContext) Restart { return Restart{} }).
+		WithSubmitHandler(func(ctx register.PageContext, v Restart) { restarted = append(restarted, v.Worker) })
+	scale := MustNewForm(func(register.PageContext) Scale { return Scale{} }).
+		WithSubmitHandler(func(ctx register.PageContext, v Scale) { scaled = append(scaled, v.Replicas) })
+	site := register.NewSite()
+	// buffered, so a post the page refuses can still be given its status
+	site.SetRenderMode(register.RenderBuffered)
+	site.RegisterPage(nil, register.RootPageId, (&ContainerPage{}).WithName("workers").WithComponent(restart).WithComponent(scale))
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	body, _ := io.ReadAll(rec.Result().Body)
+	token := csrfInput.FindStringSubmatch(string(body))[1]
+	targets := regexp.MustCompile(`name="`+PostTargetFieldName+`" value="([^"]+)"`).FindAllStringSubmatch(string(body), -1)
+	assert.Len(t, targets, 2)
+	// the targets are the ids of the components, so they don't change when the server restarts
+	assert.Equal(t, "form-1", targets[0][1])
+	assert.Equal(t, "form-2", targets[1][1])
+
+	post := func(form url.Values) int {
+		form.Set(register.CSRFFieldName, token)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, post(url.Values{"Worker": {"w1"}, PostTargetFieldName: {targets[0][1]}}))
+	assert.Equal(t, http.StatusOK, post(url.Values{"Replicas": {"3"}, PostTargetFieldName: {targets[1][1]}}))
+	assert.Equal(t, http.StatusBadRequest, post(url.Values{"Replicas": {"3"}, PostTargetFieldName: {"unknown"}}))
+	assert.Equal(t, []string{"w1"}, restarted)
+	assert.Equal(t, []int{3}, scaled)
+}
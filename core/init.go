@@ -3,16 +3,24 @@ package core
 import (
 	"sort"
 
+	"github.com/finite8/gooey/art"
 	"github.com/finite8/gooey/register"
 )
 
 func init() {
-	register.DefaultLayout = createBaseLayout()
-	register.CorePages().ErrorPage = createBaseErrorPage()
 	CoreFS = *register.NewVirtualFS("GoopCoreAssets")
 	register.RegisterFileSystem("GoopCoreAssets", CoreFS)
+	defaultFavicons = []Favicon{
+		{Source: CoreFS.SetFileBytes("favicon-16x16.png", art.Favicon16), Sizes: "16x16"},
+		{Source: CoreFS.SetFileBytes("favicon-32x32.png", art.Favicon32), Sizes: "32x32"},
+	}
+	register.DefaultLayout = createBaseLayout()
+	register.CorePages().ErrorPage = createBaseErrorPage()
 }
 
+// the icons every CommonLayout starts with
+var defaultFavicons []Favicon
+
 func createBaseLayout() *CommonLayout {
 	cl := NewCommonLayout()
 	cl.Nav = NewListComponent(func(pc register.PageContext) (interface{}, error) {
@@ -25,7 +33,7 @@ func createBaseLayout() *CommonLayout {
 
 func createBaseErrorPage() Page {
 	ep := &ContainerPage{}
-	ep = ep.WithName("error").WithComponent(NewContainerComponent().WithStyle(NewCardRenderer().WithWidthPortion(Portion75)).WithComponent(NewTextPrimitve("oops")))
+	ep = ep.WithName("error").WithComponent(NewContainerComponent().WithStyle(NewCardRenderer().WithWidthPortion(Portion75)).WithComponent(NewErrorDetailsComponent()))
 	return ep
 }
 
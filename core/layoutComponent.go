@@ -42,6 +42,10 @@ func (cc *LayoutComponent) GetChildren() (ret []Component) {
 	return
 }
 
+func (cc *LayoutComponent) directChildren() []Component {
+	return cc.children
+}
+
 func (cc *LayoutComponent) Write(ctx register.PageContext, w PageWriter) {
 	io.WriteString(w, `<table>`)
 	colPos := 0
@@ -53,7 +57,7 @@ func (cc *LayoutComponent) Write(ctx register.PageContext, w PageWriter) {
 		}
 		colPos++
 		io.WriteString(w, `<td><div>`)
-		child.Write(ctx, w)
+		Write(ctx, w, child)
 		io.WriteString(w, `</div></td>`)
 		if colPos == cc.columnCount {
 			io.WriteString(w, "</tr>")
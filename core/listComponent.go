@@ -48,6 +48,11 @@ func ArrayToRenderableArray(arrayOfValues interface{}) []Renderable {
 
 }
 
+// Data returns the data the component shows
+func (lc *ListComponent) Data(ctx register.PageContext) (interface{}, error) {
+	return lc.dataGetter(ctx)
+}
+
 func (lc *ListComponent) Write(ctx register.PageContext, w PageWriter) {
 	data, err := lc.dataGetter(ctx)
 	if err != nil {
@@ -91,6 +91,11 @@ func (oc *ObjectComponent) OnRegister(ctx register.Registerer) {
 
 }
 
+// Data returns the data the component shows
+func (oc *ObjectComponent) Data(ctx register.PageContext) (interface{}, error) {
+	return oc.dataGetter(ctx)
+}
+
 func (oc *ObjectComponent) Write(ctx register.PageContext, w PageWriter) {
 	data, err := oc.dataGetter(ctx)
 	if err != nil {
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"html/template"
 	"reflect"
-	"strings"
 
 	"github.com/finite8/gooey/register"
 )
@@ -100,7 +99,9 @@ func makeRenderableInternal(v interface{}, opt RenderOption, currDepth int) Rend
 		return l
 	case register.PageStructure:
 		// a page being passed here is assumed to be a link (embedding is not allowed)
-		l := NewLinkPrimitive(vt.Title(), "", vt.Page())
+		// the title can contain values from the url
+		l := NewLinkPrimitive(vt.Title(), "", vt.Url())
+		l.Tooltip = vt.Description()
 		return l
 	default:
 		var val interface{}
@@ -209,13 +210,14 @@ func NewTextPrimitve(val string) *TextRenderer {
 	}
 }
 
-var Template_Text = `<span {{.Attr}}>{{.Value}}</span>`
+var Template_Text = `<span{{.Attr}}>{{.Value}}</span>`
 
 func (tr *TextRenderer) Write(ctx register.PageContext, w PageWriter) {
 	t := template.Must(template.New("text").Parse(Template_Text))
 
-	t.Execute(w, map[string]string{
-		"Attr":  createTagAttribs("class", tr.Class),
+	t.Execute(w, map[string]interface{}{
+		// the values of the attributes are escaped as they are created
+		"Attr":  template.HTMLAttr(createTagAttribs("class", tr.Class)),
 		"Value": tr.Value,
 	})
 }
@@ -227,7 +229,9 @@ type LinkRenderer struct {
 	Destination interface{}
 	Target      string
 	Class       string
-	classText   string
+	// shown when hovering over the link
+	Tooltip   string
+	classText string
 }
 
 func NewLinkPrimitive(text, target string, dest interface{}) *LinkRenderer {
@@ -238,23 +242,20 @@ func NewLinkPrimitive(text, target string, dest interface{}) *LinkRenderer {
 	}
 }
 
+var linkTemplate = template.Must(template.New("link").Parse(Link_Text))
+
 func (lr *LinkRenderer) Write(ctx register.PageContext, w PageWriter) {
-	// t := template.Must(template.New("link").Parse(Link_Text))
 	u, err := ctx.ResolveUrl(lr.Destination)
 	if err != nil {
 		WriteComponentError(ctx, lr, err, w)
 		return
 	}
-	// var tv struct {
-	// 	DestURL template.URL
-	// 	Attr    string
-	// 	Value   string
-	// }
-	outString := Link_Text
-	outString = strings.ReplaceAll(outString, "{{.URL}}", u.String())
-	outString = strings.ReplaceAll(outString, "{{.Attr}}", createTagAttribs("class", lr.Class))
-	outString = strings.ReplaceAll(outString, "{{.Value}}", lr.Text)
-	_, err = w.Write([]byte(outString))
+	// the template takes care of escaping the url and text, and of urls that could run script
+	err = linkTemplate.Execute(w, map[string]interface{}{
+		"URL":   u.String(),
+		"Attr":  template.HTMLAttr(createTagAttribs("class", lr.Class, "title", lr.Tooltip)),
+		"Value": lr.Text,
+	})
 	if err != nil {
 		WriteComponentError(ctx, lr, err, w)
 		return
@@ -0,0 +1,56 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+type funcComponent struct {
+	nilComponent
+	f func(ctx register.PageContext, w PageWriter)
+}
+
+func (fc *funcComponent) Write(ctx register.PageContext, w PageWriter) {
+	fc.f(ctx, w)
+}
+
+func TestEscaping(t *testing.T) {
+	hostile := `<img src=x onerror="alert(1)">`
+	page := (&ContainerPage{}).WithName("escaping").
+		WithComponent(NewContainerComponent().WithStyle(NewCardRenderer().WithHeader(hostile)).
+			WithComponent(NewTextPrimitve(hostile))).
+		WithComponent(&funcComponent{f: func(ctx register.PageContext, w PageWriter) {
+			NewLinkPrimitive(hostile, "", "javascript:alert(1)").Write(ctx, w)
+			NewTag("p", map[string]interface{}{"title": hostile, "hidden": nil}, hostile).Write(ctx, w)
+			w.WriteElement(ctx, register.SafeHTML("<hr class=\"intended\">"))
+		}})
+	site := register.NewSite()
+	site.RegisterPage(nil, register.RootPageId, page)
+
+	rec := httptest.NewRecorder()
+	site.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	assert.NotContains(t, body, "<img")
+	assert.Contains(t, body, `<h4 class="card-title">&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</h4>`)
+	assert.Contains(t, body, `href="#ZgotmplZ"`)
+	assert.Contains(t, body, `<p hidden title="&lt;img src=x onerror=&#34;alert(1)&#34;&gt;">`)
+	assert.Contains(t, body, `<hr class="intended">`)
+}
+
+func TestLinkTitles(t *testing.T) {
+	site := register.NewSite()
+	site.RegisterPage(nil, register.RootPageId, (&ContainerPage{}).WithName("R&D <ops>"))
+
+	rec := httptest.NewRecorder()
+	site.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := rec.Body.String()
+
+	// the nav links are built from the page structure, and are only escaped once
+	assert.Contains(t, body, ">R&amp;D &lt;ops&gt;</a>")
+	assert.NotContains(t, body, "&amp;amp;")
+}
@@ -73,6 +73,11 @@ func (tc *TableComponent) OnRegister(ctx register.Registerer) {
 
 }
 
+// Data returns the data the component shows
+func (tc *TableComponent) Data(ctx register.PageContext) (interface{}, error) {
+	return tc.dataGetter(ctx)
+}
+
 func (tc *TableComponent) Write(ctx register.PageContext, w PageWriter) {
 	data, err := tc.dataGetter(ctx)
 	if err != nil {
@@ -1,3 +1,6 @@
 package core
 
 var ErrTemplate = `<span class="error">{{.Text}}</span>`
+
+// RestrictedTemplate is rendered in place of a component the user does not have access to
+var RestrictedTemplate = `<div class="GOOEY_restricted text-muted">You do not have access to this content</div>`
@@ -12,10 +12,10 @@ import (
 )
 
 var textStreamTemplate = template.Must(template.New("stream").Parse(`<pre id="output"></pre>
-<script>
+<script nonce="{{.Nonce}}">
    
     var output = document.getElementById("output");
-    var socket = new WebSocket("ws://{{.StreamURL}}");
+    var socket = new WebSocket("{{.StreamURL}}");
 
     socket.onopen = function () {
         output.innerHTML += "Status: Connected\n";
@@ -48,8 +48,18 @@ func NewStreamComponent(f func(context.Context, chan<- string) error) *TextStrea
 
 func (tc *TextStreamComponent) Write(ctx register.PageContext, w PageWriter) {
 	strmUrl := ctx.GetPageUrl(tc.streamPage)
-	var tmplLoad struct{ StreamURL template.URL }
-	tmplLoad.StreamURL = template.URL(strmUrl.Host + strmUrl.Path)
+	// the socket needs to be secure if the page was served securely
+	if strmUrl.Scheme == "https" {
+		strmUrl.Scheme = "wss"
+	} else {
+		strmUrl.Scheme = "ws"
+	}
+	var tmplLoad struct {
+		StreamURL template.URL
+		Nonce     string
+	}
+	tmplLoad.StreamURL = template.URL(strmUrl.String())
+	tmplLoad.Nonce = ctx.Nonce()
 	textStreamTemplate.Execute(w, tmplLoad)
 }
 
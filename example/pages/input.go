@@ -17,7 +17,8 @@ type inputPage struct {
 
 func NewInput() *inputPage {
 	ip := &inputPage{}
-	ip.WithName("Input Form").WithComponent(core.MustNewForm(func(pc register.PageContext) TestStruct {
+	// redirecting after the post means refreshing the page doesn't add the result again
+	ip.WithName("Input Form").WithRedirectAfterPost(true).WithComponent(core.MustNewForm(func(pc register.PageContext) TestStruct {
 		return TestStruct{
 			Name: "default name",
 		}
@@ -25,7 +26,7 @@ func NewInput() *inputPage {
 		d, _ := json.Marshal(ts)
 		fmt.Println(string(d))
 		ip.results = append(ip.results, ts)
-	}))
+	}).WithMessages("Result added", "Validation failed"))
 	ip.WithComponent(core.NewTableComponent(func(pc register.PageContext) (interface{}, error) {
 		return ip.results, nil
 	}))
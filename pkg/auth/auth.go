@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/finite8/gooey/register"
+	"github.com/sirupsen/logrus"
+)
+
+// Provider is a mechanism that is able to identify a user. Providers either sign users in interactively through the signin page
+// (InteractiveProvider) or identify them from each request (register.Authenticator), or both.
+type Provider interface {
+	Name() string
+}
+
+// InteractiveProvider is a provider that the user signs in to via the signin page.
+type InteractiveProvider interface {
+	Provider
+	// WriteSigninForm renders the provider's section of the signin page. Submitting the form should send the user to the action url.
+	WriteSigninForm(ctx register.PageContext, w io.Writer, action *url.URL) error
+	// HandleSignin is called when the user arrives at the provider's url (the action of the form, or any callback). The provider either
+	// identifies the user, or redirects them elsewhere to continue signing in. callback is the url of the provider, which will come back here.
+	HandleSignin(ctx register.PageContext, r *http.Request, callback *url.URL) (SigninResult, error)
+}
+
+// SigninResult is the outcome of an InteractiveProvider handling a signin
+type SigninResult struct {
+	// The user that has signed in
+	User *register.User
+	// If set, the browser is sent here to continue the signin (i.e: to a third party identity provider)
+	Redirect *url.URL
+}
+
+// SigninError is an error that is safe to show to the user on the signin page. Any other errors are logged and a generic message is shown.
+// The message is kept in the session rather than the url, so nobody can link to the signin page with a message of their own.
+type SigninError string
+
+func (se SigninError) Error() string {
+	return string(se)
+}
+
+const (
+	// the error to show the next time the signin page is shown
+	errorSessionKey = "GOOEY_auth_error"
+	// where the user needs to go once a signin that left the site completes
+	returnSessionKey = "GOOEY_auth_return"
+)
+
+// RequireSignIn is an authorizer that only allows signed in users to access a page. Anyone else is sent to the signin page.
+func RequireSignIn() register.PageAuthorizer {
+	return register.AuthorizerFunc(func(page register.Page, ctx register.PageContext) register.AuthResult {
+		if ctx.User() == nil {
+			return register.AuthResult{RequestAuth: true}
+		}
+		return register.AuthResult{}
+	})
+}
+
+// Install adds the given providers to the site. Interactive providers are added to a signin page (registered as register.SigninPageId)
+// and providers that identify users from the request are added as authenticators. To force users to sign in, add RequireSignIn as an authorizer.
+func Install(site *register.Site, providers ...Provider) (*SigninPage, error) {
+	sp := NewSigninPage()
+	for _, p := range providers {
+		used := false
+		if ip, ok := p.(InteractiveProvider); ok {
+			sp.providers = append(sp.providers, ip)
+			used = true
+		}
+		if a, ok := p.(register.Authenticator); ok {
+			site.AddAuthenticator(a)
+			used = true
+		}
+		if !used {
+			return nil, fmt.Errorf("%T is not a usable provider", p)
+		}
+	}
+	return sp, site.RegisterPage(register.RootPageId, register.SigninPageId, sp, register.Hidden())
+}
+
+var signinTemplate = template.Must(template.New("signin").Parse(`<div class="GOOEY GOOEY_signin">
+{{if .Error}}<div class="alert alert-danger">{{.Error}}</div>{{end}}
+{{range .Forms}}<div class="GOOEY GOOEY_signinprovider">{{.}}</div>{{end}}
+{{if .User}}<div>Signed in as {{.User}}. {{.Signout}}</div>{{end}}
+</div>`))
+
+var signoutTemplate = template.Must(template.New("signout").Parse(`<form class="GOOEY GOOEY_signout" action="{{.Action}}" method="post">{{.CSRF}}<button type="submit" class="btn btn-link">Sign out</button></form>`))
+
+// signoutForm renders the form that signs the user out. Signing out has to be a POST, so it is covered by the CSRF check.
+func signoutForm(ctx register.PageContext, action string) template.HTML {
+	sb := &strings.Builder{}
+	if err := signoutTemplate.Execute(sb, map[string]interface{}{
+		"Action": action,
+		"CSRF":   register.CSRFField(ctx),
+	}); err != nil {
+		logrus.WithError(err).Error("failed to render signout form")
+	}
+	return template.HTML(sb.String())
+}
+
+// SigninPage lists the forms of each interactive provider. Each provider is given its own private page to complete the signin.
+type SigninPage struct {
+	providers     []InteractiveProvider
+	providerPages map[InteractiveProvider]*providerPage
+	signout       *signoutPage
+}
+
+var _ register.ComplexPage = (*SigninPage)(nil)
+
+func NewSigninPage() *SigninPage {
+	return &SigninPage{
+		providerPages: map[InteractiveProvider]*providerPage{},
+		signout:       &signoutPage{},
+	}
+}
+
+// SignoutPage returns the page that signs the user out
+func (sp *SigninPage) SignoutPage() register.Page {
+	return sp.signout
+}
+
+func (sp *SigninPage) Name() string {
+	return "Sign in"
+}
+
+var providerIdCleaner = regexp.MustCompile("[^a-z0-9]+")
+
+func (sp *SigninPage) OnHandlerAdded(reg register.Registerer) {
+	for _, p := range sp.providers {
+		pp := &providerPage{
+			provider: p,
+			signin:   sp,
+		}
+		sp.providerPages[p] = pp
+		reg.RegisterPrivateSubPage(providerIdCleaner.ReplaceAllString(strings.ToLower(p.Name()), "-"), pp)
+	}
+	reg.RegisterPrivateSubPage("signout", sp.signout)
+}
+
+// QueryBehaviour creates the CSRF token of the forms before the page starts to be sent, so a new visitor is given their session cookie
+func (sp *SigninPage) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
+	register.CSRFToken(ctx)
+	return b
+}
+
+func (sp *SigninPage) Handler(ctx register.PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	var data struct {
+		Error   string
+		Forms   []template.HTML
+		User    string
+		Signout template.HTML
+	}
+	if msg, ok := ctx.Session().Get(errorSessionKey); ok {
+		ctx.Session().Remove(errorSessionKey)
+		data.Error = msg
+	}
+	if u := ctx.User(); u != nil {
+		data.User = u.DisplayName()
+		data.Signout = signoutForm(ctx, ctx.GetPageUrl(sp.signout).String())
+	}
+	for _, p := range sp.providers {
+		action := ctx.GetPageUrl(sp.providerPages[p])
+		if returnTo := r.URL.Query().Get(register.ReturnUrlParam); returnTo != "" {
+			action.RawQuery = url.Values{register.ReturnUrlParam: []string{returnTo}}.Encode()
+		}
+		sb := &strings.Builder{}
+		if err := p.WriteSigninForm(ctx, sb, action); err != nil {
+			logrus.WithError(err).Errorf("failed to render signin form for %s", p.Name())
+			continue
+		}
+		data.Forms = append(data.Forms, template.HTML(sb.String()))
+	}
+	if err := signinTemplate.Execute(w, data); err != nil {
+		logrus.WithError(err).Error("failed to render signin page")
+	}
+	return nil
+}
+
+// redirectWithError sends the user back to the signin page to show them the error
+func (sp *SigninPage) redirectWithError(ctx register.PageContext, w http.ResponseWriter, r *http.Request, err error) {
+	var se SigninError
+	if !errors.As(err, &se) {
+		logrus.WithError(err).Error("signin failed")
+		se = SigninError("sign in failed")
+	}
+	ctx.Session().Set(errorSessionKey, se.Error())
+	u := ctx.GetPageUrl(sp)
+	if returnTo := r.URL.Query().Get(register.ReturnUrlParam); returnTo != "" {
+		u.RawQuery = url.Values{register.ReturnUrlParam: []string{returnTo}}.Encode()
+	}
+	http.Redirect(w, r, u.String(), http.StatusSeeOther)
+}
+
+// providerPage is where a provider completes its signin. It never renders HTML, it only ever redirects.
+type providerPage struct {
+	provider InteractiveProvider
+	signin   *SigninPage
+}
+
+func (pp *providerPage) Name() string {
+	return pp.provider.Name()
+}
+
+func (pp *providerPage) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
+	return b.WithRenderHTML(false)
+}
+
+func (pp *providerPage) Handler(ctx register.PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	res, err := pp.provider.HandleSignin(ctx, r, ctx.GetPageUrl(pp))
+	if err == nil && res.User == nil && res.Redirect == nil {
+		err = errors.New("provider did not return a result")
+	}
+	if err != nil {
+		pp.signin.redirectWithError(ctx, w, r, err)
+		return nil
+	}
+	if res.Redirect != nil {
+		// the signin continues elsewhere, so we need to remember where the user is going once it is complete
+		ctx.Session().Set(returnSessionKey, r.URL.Query().Get(register.ReturnUrlParam))
+		http.Redirect(w, r, res.Redirect.String(), http.StatusFound)
+		return nil
+	}
+	ctx.Session().SetUser(res.User)
+	returnTo := r.URL.Query().Get(register.ReturnUrlParam)
+	if v, ok := ctx.Session().Get(returnSessionKey); ok {
+		ctx.Session().Remove(returnSessionKey)
+		if returnTo == "" {
+			returnTo = v
+		}
+	}
+	http.Redirect(w, r, register.ResolveReturnUrl(ctx, returnTo).String(), http.StatusSeeOther)
+	return nil
+}
+
+type signoutPage struct {
+}
+
+func (sp *signoutPage) Name() string {
+	return "Sign out"
+}
+
+// QueryBehaviour makes sure nothing is sent before the redirect after signing out
+func (sp *signoutPage) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
+	return b.WithRenderMode(register.RenderBuffered)
+}
+
+// Handler only signs the user out on a POST. Otherwise a link or image on any page could do it. A GET shows the form to confirm.
+func (sp *signoutPage) Handler(ctx register.PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	if r.Method != http.MethodPost {
+		io.WriteString(w, string(signoutForm(ctx, ctx.GetPageUrl(sp).String())))
+		return nil
+	}
+	ctx.Session().Destroy()
+	http.Redirect(w, r, ctx.GetPageUrl(ctx.SiteRoot().Page()).String(), http.StatusSeeOther)
+	return nil
+}
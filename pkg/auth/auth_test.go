@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/finite8/gooey/register"
+	"github.com/stretchr/testify/assert"
+)
+
+type whoamiPage struct {
+}
+
+func (wp *whoamiPage) Name() string {
+	return "whoami"
+}
+
+func (wp *whoamiPage) QueryBehaviour(ctx register.PageContext, b register.Behaviour) register.Behaviour {
+	return b.WithRenderHTML(false)
+}
+
+func (wp *whoamiPage) Handler(ctx register.PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	fmt.Fprintf(w, "user:%s", ctx.User().DisplayName())
+	return nil
+}
+
+// client follows the site the way a browser would, keeping its cookies and sending the csrf token of the last form it was shown
+type client struct {
+	t       *testing.T
+	h       http.Handler
+	cookies map[string]*http.Cookie
+	token   string
+}
+
+var csrfInput = regexp.MustCompile(`name="` + register.CSRFFieldName + `" value="([^"]+)"`)
+
+func (c *client) do(method, target string, form url.Values, mod func(r *http.Request)) *http.Response {
+	var r *http.Request
+	if form != nil {
+		if c.token != "" {
+			form.Set(register.CSRFFieldName, c.token)
+		}
+		r = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	for _, ck := range c.cookies {
+		r.AddCookie(ck)
+	}
+	if mod != nil {
+		mod(r)
+	}
+	rec := httptest.NewRecorder()
+	c.h.ServeHTTP(rec, r)
+	if m := csrfInput.FindStringSubmatch(rec.Body.String()); m != nil {
+		c.token = m[1]
+	}
+	for _, ck := range rec.Result().Cookies() {
+		if ck.MaxAge < 0 {
+			delete(c.cookies, ck.Name)
+		} else {
+			c.cookies[ck.Name] = ck
+		}
+	}
+	return rec.Result()
+}
+
+func newTestSite(t *testing.T, providers ...Provider) (*register.Site, *client) {
+	site := register.NewSite()
+	assert.NoError(t, site.RegisterPage(nil, register.RootPageId, &whoamiPage{}))
+	_, err := Install(site, providers...)
+	assert.NoError(t, err)
+	site.AddAuthorizer(RequireSignIn())
+	return site, &client{t: t, h: site.Handler(), cookies: map[string]*http.Cookie{}}
+}
+
+func body(resp *http.Response) string {
+	sb := &strings.Builder{}
+	buf := make([]byte, 1024)
+	for {
+		n, err := resp.Body.Read(buf)
+		sb.Write(buf[:n])
+		if err != nil {
+			return sb.String()
+		}
+	}
+}
+
+func Test_PasswordSignin(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	assert.NoError(t, err)
+	users := NewStaticUsers(StaticUser{Username: "jo", PasswordHash: hash, User: register.User{Name: "Jo"}})
+	_, c := newTestSite(t, NewPasswordProvider(users), NewBasicAuthProvider(users),
+		NewBearerTokenProvider(map[string]*register.User{"tok": {Id: "robot", Name: "Robot"}}))
+
+	resp := c.do(http.MethodGet, "/", nil, nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "http://example.com/signin?returnTo=%2F", resp.Header.Get("Location"))
+
+	resp = c.do(http.MethodGet, "/signin?returnTo=%2F", nil, nil)
+	assert.Contains(t, body(resp), `action="http://example.com/signin/password?returnTo=%2F"`)
+
+	resp = c.do(http.MethodPost, "/signin/password?returnTo=%2F", url.Values{"username": {"jo"}, "password": {"wrong"}}, nil)
+	assert.Equal(t, "http://example.com/signin?returnTo=%2F", resp.Header.Get("Location"))
+	// the error is shown once
+	assert.Contains(t, body(c.do(http.MethodGet, "/signin?returnTo=%2F", nil, nil)), "invalid username or password")
+	assert.NotContains(t, body(c.do(http.MethodGet, "/signin?returnTo=%2F", nil, nil)), "alert-danger")
+	// and the signin page can't be made to show anything else
+	assert.NotContains(t, body(c.do(http.MethodGet, "/signin?error=call+us+on+555", nil, nil)), "555")
+
+	resp = c.do(http.MethodPost, "/signin/password?returnTo=%2F", url.Values{"username": {"jo"}, "password": {"hunter2"}}, nil)
+	assert.Equal(t, "http://example.com/", resp.Header.Get("Location"))
+	assert.Equal(t, "user:Jo", body(c.do(http.MethodGet, "/", nil, nil)))
+
+	// signing out has to be posted from the form
+	resp = c.do(http.MethodGet, "/signin/signout", nil, nil)
+	assert.Contains(t, body(resp), `action="http://example.com/signin/signout" method="post"`)
+	assert.Equal(t, "user:Jo", body(c.do(http.MethodGet, "/", nil, nil)))
+	c.do(http.MethodPost, "/signin/signout", url.Values{}, nil)
+	assert.Equal(t, http.StatusFound, c.do(http.MethodGet, "/", nil, nil).StatusCode)
+
+	// non-interactive callers
+	resp = c.do(http.MethodGet, "/", nil, func(r *http.Request) { r.SetBasicAuth("jo", "hunter2") })
+	assert.Equal(t, "user:Jo", body(resp))
+	resp = c.do(http.MethodGet, "/", nil, func(r *http.Request) { r.SetBasicAuth("jo", "nope") })
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+	resp = c.do(http.MethodGet, "/", nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok") })
+	assert.Equal(t, "user:Robot", body(resp))
+}
+
+// testIssuer is a minimal OpenID Connect provider
+type testIssuer struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	nonce string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ti := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 ti.URL,
+			"authorization_endpoint": ti.URL + "/authorize",
+			"token_endpoint":         ti.URL + "/token",
+			"jwks_uri":               ti.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "secret" || r.PostFormValue("code") != "good-code" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token": ti.sign(t, map[string]interface{}{
+				"iss":   ti.URL,
+				"sub":   "123",
+				"aud":   "client",
+				"exp":   time.Now().Add(time.Minute).Unix(),
+				"nonce": ti.nonce,
+				"name":  "Sam",
+			}),
+		})
+	})
+	ti.Server = httptest.NewServer(mux)
+	return ti
+}
+
+func (ti *testIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	enc := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := enc(map[string]string{"alg": "RS256", "kid": "k1"}) + "." + enc(claims)
+	h := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, ti.key, crypto.SHA256, h[:])
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func Test_OIDCSignin(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	_, c := newTestSite(t, NewOIDCProvider(OIDCConfig{Name: "Test", IssuerURL: ti.URL, ClientID: "client", ClientSecret: "secret"}))
+
+	c.do(http.MethodGet, "/signin", nil, nil)
+	resp := c.do(http.MethodPost, "/signin/test?returnTo=%2F%3Fx%3D1", url.Values{}, nil)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	authUrl, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, ti.URL+"/authorize", authUrl.Scheme+"://"+authUrl.Host+authUrl.Path)
+	assert.Equal(t, "http://example.com/signin/test", authUrl.Query().Get("redirect_uri"))
+	ti.nonce = authUrl.Query().Get("nonce")
+	state := authUrl.Query().Get("state")
+
+	// a forged state is refused, and uses up the signin
+	resp = c.do(http.MethodGet, "/signin/test?code=good-code&state=forged", nil, nil)
+	assert.Equal(t, "http://example.com/signin", resp.Header.Get("Location"))
+	assert.Contains(t, body(c.do(http.MethodGet, "/signin", nil, nil)), "alert-danger")
+	resp = c.do(http.MethodGet, "/signin/test?code=good-code&state="+state, nil, nil)
+	assert.Equal(t, "http://example.com/signin", resp.Header.Get("Location"))
+	// what the provider says went wrong is not shown
+	c.do(http.MethodGet, "/signin/test?error=call+us+on+555", nil, nil)
+	signin := body(c.do(http.MethodGet, "/signin", nil, nil))
+	assert.Contains(t, signin, "Test did not sign you in")
+	assert.NotContains(t, signin, "555")
+
+	resp = c.do(http.MethodPost, "/signin/test", url.Values{}, nil)
+	authUrl, _ = url.Parse(resp.Header.Get("Location"))
+	ti.nonce = authUrl.Query().Get("nonce")
+	resp = c.do(http.MethodGet, "/signin/test?code=good-code&state="+authUrl.Query().Get("state"), nil, nil)
+	assert.Equal(t, "http://example.com/", resp.Header.Get("Location"))
+	assert.Equal(t, "user:Sam", body(c.do(http.MethodGet, "/", nil, nil)))
+}
+
+func Test_OIDCRejectsBadTokens(t *testing.T) {
+	ti := newTestIssuer(t)
+	defer ti.Close()
+	op := NewOIDCProvider(OIDCConfig{IssuerURL: ti.URL, ClientID: "client"})
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{"iss": ti.URL, "sub": "1", "aud": []string{"other", "client"}, "exp": time.Now().Add(time.Minute).Unix(), "nonce": "n"}
+	}
+	_, err := op.verifyIDToken(ti.sign(t, claims()), "n")
+	assert.NoError(t, err)
+
+	for name, mod := range map[string]func(c map[string]interface{}){
+		"audience": func(c map[string]interface{}) { c["aud"] = "other" },
+		"issuer":   func(c map[string]interface{}) { c["iss"] = "https://evil" },
+		"expired":  func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"nonce":    func(c map[string]interface{}) { c["nonce"] = "x" },
+	} {
+		c := claims()
+		mod(c)
+		_, err := op.verifyIDToken(ti.sign(t, c), "n")
+		assert.Error(t, err, name)
+	}
+	tok := ti.sign(t, claims())
+	_, err = op.verifyIDToken(tok[:len(tok)-4]+"AAAA", "n")
+	assert.Error(t, err, "signature")
+}
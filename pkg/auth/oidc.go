@@ -0,0 +1,373 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/finite8/gooey/register"
+	"github.com/sirupsen/logrus"
+)
+
+// OIDCConfig configures a connection to an OpenID Connect identity provider
+type OIDCConfig struct {
+	// The name shown to users on the signin page (i.e: "Sign in with <Name>")
+	Name string
+	// The issuer of the provider. The configuration is discovered from <IssuerURL>/.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// The scopes to request (default "openid profile email")
+	Scopes []string
+	// The client used to talk to the provider (default http.DefaultClient)
+	HTTPClient *http.Client
+}
+
+// OIDCProvider signs users in with the authorization code flow of an OpenID Connect identity provider
+type OIDCProvider struct {
+	cfg       OIDCConfig
+	mux       sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]*rsa.PublicKey
+}
+
+var _ InteractiveProvider = (*OIDCProvider)(nil)
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+const (
+	oidcStateKey = "GOOEY_oidc_state"
+	// allowed difference between our clock and the provider's
+	oidcClockSkew = time.Minute
+)
+
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.IssuerURL
+	}
+	return &OIDCProvider{
+		cfg: cfg,
+	}
+}
+
+func (op *OIDCProvider) Name() string {
+	return op.cfg.Name
+}
+
+var oidcFormTemplate = template.Must(template.New("oidc").Parse(`<form action="{{.Action}}" method="post">{{.CSRF}}
+<button type="submit" class="btn btn-primary">Sign in with {{.Name}}</button>
+</form>`))
+
+func (op *OIDCProvider) WriteSigninForm(ctx register.PageContext, w io.Writer, action *url.URL) error {
+	return oidcFormTemplate.Execute(w, map[string]interface{}{
+		"Action": action,
+		"Name":   op.cfg.Name,
+		"CSRF":   register.CSRFField(ctx),
+	})
+}
+
+func (op *OIDCProvider) HandleSignin(ctx register.PageContext, r *http.Request, callback *url.URL) (SigninResult, error) {
+	q := r.URL.Query()
+	switch {
+	case q.Get("error") != "":
+		// anyone can link to the callback, so what the provider says is logged rather than shown
+		logrus.WithField("error", q.Get("error")).Warnf("%s did not sign the user in", op.cfg.Name)
+		return SigninResult{}, SigninError(fmt.Sprintf("%s did not sign you in", op.cfg.Name))
+	case q.Get("code") != "":
+		return op.complete(ctx, r, callback)
+	case r.Method == http.MethodPost:
+		return op.begin(ctx, callback)
+	}
+	return SigninResult{}, SigninError("sign in must be started from the sign in page")
+}
+
+// begin sends the user to the provider to sign in
+func (op *OIDCProvider) begin(ctx register.PageContext, callback *url.URL) (SigninResult, error) {
+	d, err := op.getDiscovery()
+	if err != nil {
+		return SigninResult{}, err
+	}
+	state, nonce := randomToken(), randomToken()
+	ctx.Session().Set(oidcStateKey, state+" "+nonce)
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return SigninResult{}, err
+	}
+	u.RawQuery = url.Values{
+		"response_type": []string{"code"},
+		"client_id":     []string{op.cfg.ClientID},
+		"redirect_uri":  []string{callback.String()},
+		"scope":         []string{strings.Join(op.cfg.Scopes, " ")},
+		"state":         []string{state},
+		"nonce":         []string{nonce},
+	}.Encode()
+	return SigninResult{Redirect: u}, nil
+}
+
+// complete is called when the provider sends the user back to us
+func (op *OIDCProvider) complete(ctx register.PageContext, r *http.Request, callback *url.URL) (SigninResult, error) {
+	stored, ok := ctx.Session().Get(oidcStateKey)
+	if !ok {
+		return SigninResult{}, SigninError("sign in has expired, please try again")
+	}
+	// the state can only be used once
+	ctx.Session().Remove(oidcStateKey)
+	parts := strings.SplitN(stored, " ", 2)
+	if len(parts) != 2 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(r.URL.Query().Get("state"))) != 1 {
+		return SigninResult{}, errors.New("oidc state does not match")
+	}
+	rawToken, err := op.exchange(r.URL.Query().Get("code"), callback)
+	if err != nil {
+		return SigninResult{}, err
+	}
+	claims, err := op.verifyIDToken(rawToken, parts[1])
+	if err != nil {
+		return SigninResult{}, err
+	}
+	u := &register.User{
+		Id:    claims.Issuer + "|" + claims.Subject,
+		Name:  claims.Name,
+		Email: claims.Email,
+		Attributes: map[string]string{
+			"sub": claims.Subject,
+			"iss": claims.Issuer,
+		},
+	}
+	if u.Name == "" {
+		u.Name = claims.PreferredUsername
+	}
+	return SigninResult{User: u}, nil
+}
+
+func (op *OIDCProvider) exchange(code string, callback *url.URL) (string, error) {
+	d, err := op.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":   []string{"authorization_code"},
+		"code":         []string{code},
+		"redirect_uri": []string{callback.String()},
+	}
+	req, err := http.NewRequest(http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(op.cfg.ClientID), url.QueryEscape(op.cfg.ClientSecret))
+	var tr struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := op.getJSON(req, &tr); err != nil {
+		return "", fmt.Errorf("failed to exchange code: %w", err)
+	}
+	if tr.IDToken == "" {
+		return "", errors.New("token response did not include an id_token")
+	}
+	return tr.IDToken, nil
+}
+
+type oidcClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          oidcAudience `json:"aud"`
+	Expiry            int64        `json:"exp"`
+	Nonce             string       `json:"nonce"`
+	Name              string       `json:"name"`
+	Email             string       `json:"email"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// oidcAudience can be either a single string or a list of strings
+type oidcAudience []string
+
+func (a *oidcAudience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = oidcAudience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+func (op *OIDCProvider) verifyIDToken(raw, nonce string) (*oidcClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id token is malformed")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("id token algorithm %q is not supported", header.Alg)
+	}
+	key, err := op.getKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig); err != nil {
+		return nil, fmt.Errorf("id token signature is invalid: %w", err)
+	}
+	var claims oidcClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	d, err := op.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	if claims.Issuer != d.Issuer {
+		return nil, fmt.Errorf("id token was issued by %q", claims.Issuer)
+	}
+	audOk := false
+	for _, a := range claims.Audience {
+		audOk = audOk || a == op.cfg.ClientID
+	}
+	if !audOk {
+		return nil, errors.New("id token was not issued for this client")
+	}
+	if time.Unix(claims.Expiry, 0).Add(oidcClockSkew).Before(time.Now()) {
+		return nil, errors.New("id token has expired")
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("id token nonce does not match")
+	}
+	return &claims, nil
+}
+
+func (op *OIDCProvider) getDiscovery() (*oidcDiscovery, error) {
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	if op.discovery != nil {
+		return op.discovery, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(op.cfg.IssuerURL, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var d oidcDiscovery
+	if err := op.getJSON(req, &d); err != nil {
+		return nil, fmt.Errorf("failed to discover oidc configuration: %w", err)
+	}
+	if d.Issuer != strings.TrimSuffix(op.cfg.IssuerURL, "/") && d.Issuer != op.cfg.IssuerURL {
+		return nil, fmt.Errorf("oidc issuer %q does not match the configured issuer", d.Issuer)
+	}
+	op.discovery = &d
+	return op.discovery, nil
+}
+
+// getKey returns the signing key with the given id. The keys are fetched again if the key is not known, as the provider may have rotated them.
+func (op *OIDCProvider) getKey(kid string) (*rsa.PublicKey, error) {
+	d, err := op.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	if k, ok := op.keys[kid]; ok {
+		return k, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := op.getJSON(req, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch oidc keys: %w", err)
+	}
+	op.keys = map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		op.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if k, ok := op.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("id token was signed with unknown key %q", kid)
+}
+
+func (op *OIDCProvider) getJSON(req *http.Request, v interface{}) error {
+	resp, err := op.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/finite8/gooey/register"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidCredentials is returned when a username or password is not correct
+var ErrInvalidCredentials = SigninError("invalid username or password")
+
+// CredentialVerifier checks a username and password, returning the user they belong to
+type CredentialVerifier interface {
+	Verify(username, password string) (*register.User, error)
+}
+
+// StaticUser is a user that is known ahead of time
+type StaticUser struct {
+	Username string
+	// bcrypt hash of the password (see HashPassword)
+	PasswordHash string
+	// The details of the user once signed in. If the Id is empty, the username is used.
+	User register.User
+}
+
+// StaticUsers is a fixed list of users, with their passwords held as bcrypt hashes
+type StaticUsers struct {
+	users map[string]StaticUser
+}
+
+var _ CredentialVerifier = (*StaticUsers)(nil)
+
+func NewStaticUsers(users ...StaticUser) *StaticUsers {
+	su := &StaticUsers{
+		users: make(map[string]StaticUser, len(users)),
+	}
+	for _, u := range users {
+		su.users[u.Username] = u
+	}
+	return su
+}
+
+// HashPassword creates the hash of a password for use in a StaticUser
+func HashPassword(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(h), err
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+func (su *StaticUsers) Verify(username, password string) (*register.User, error) {
+	user, ok := su.users[username]
+	hash := []byte(user.PasswordHash)
+	if !ok {
+		// still compare against something so unknown users take as long as known ones
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = bcrypt.GenerateFromPassword([]byte("GOOEY"), bcrypt.DefaultCost)
+		})
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return nil, ErrInvalidCredentials
+	}
+	u := user.User
+	if u.Id == "" {
+		u.Id = username
+	}
+	return &u, nil
+}
+
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<form action="{{.Action}}" method="post">{{.CSRF}}
+<div class="GOOEY_formgroup"><label for="username" class="GOOEY_formlabel">Username</label><input type="text" class="GOOEY_forminput" id="username" name="username" autocomplete="username"></div>
+<div class="GOOEY_formgroup"><label for="password" class="GOOEY_formlabel">Password</label><input type="password" class="GOOEY_forminput" id="password" name="password" autocomplete="current-password"></div>
+<button type="submit" class="btn btn-primary">Sign in</button>
+</form>`))
+
+// PasswordProvider signs users in with a username and password form
+type PasswordProvider struct {
+	verifier CredentialVerifier
+}
+
+var _ InteractiveProvider = (*PasswordProvider)(nil)
+
+func NewPasswordProvider(v CredentialVerifier) *PasswordProvider {
+	return &PasswordProvider{
+		verifier: v,
+	}
+}
+
+func (pp *PasswordProvider) Name() string {
+	return "password"
+}
+
+func (pp *PasswordProvider) WriteSigninForm(ctx register.PageContext, w io.Writer, action *url.URL) error {
+	return passwordFormTemplate.Execute(w, map[string]interface{}{
+		"Action": action,
+		"CSRF":   register.CSRFField(ctx),
+	})
+}
+
+func (pp *PasswordProvider) HandleSignin(ctx register.PageContext, r *http.Request, callback *url.URL) (SigninResult, error) {
+	if r.Method != http.MethodPost {
+		return SigninResult{}, SigninError("sign in must be submitted from the sign in form")
+	}
+	if err := r.ParseForm(); err != nil {
+		return SigninResult{}, err
+	}
+	u, err := pp.verifier.Verify(r.PostForm.Get("username"), r.PostForm.Get("password"))
+	return SigninResult{User: u}, err
+}
+
+// BasicAuthProvider identifies users from the HTTP Basic Authorization header. This suits scripts and other non-interactive callers.
+type BasicAuthProvider struct {
+	verifier CredentialVerifier
+	Realm    string
+}
+
+var _ register.Authenticator = (*BasicAuthProvider)(nil)
+
+func NewBasicAuthProvider(v CredentialVerifier) *BasicAuthProvider {
+	return &BasicAuthProvider{
+		verifier: v,
+		Realm:    "GOOEY",
+	}
+}
+
+func (ba *BasicAuthProvider) Name() string {
+	return "basic"
+}
+
+func (ba *BasicAuthProvider) Authenticate(w http.ResponseWriter, r *http.Request) (*register.User, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	u, err := ba.verifier.Verify(username, password)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm=%q`, ba.Realm))
+		return nil, err
+	}
+	return u, nil
+}
+
+// BearerTokenProvider identifies API callers from a bearer token in the Authorization header.
+type BearerTokenProvider struct {
+	// tokens are kept hashed so they are not lying around in memory
+	tokens map[[sha256.Size]byte]*register.User
+}
+
+var _ register.Authenticator = (*BearerTokenProvider)(nil)
+
+// NewBearerTokenProvider creates a provider from a map of tokens to the user that each token identifies
+func NewBearerTokenProvider(tokens map[string]*register.User) *BearerTokenProvider {
+	bp := &BearerTokenProvider{
+		tokens: make(map[[sha256.Size]byte]*register.User, len(tokens)),
+	}
+	for t, u := range tokens {
+		bp.tokens[sha256.Sum256([]byte(t))] = u
+	}
+	return bp
+}
+
+func (bp *BearerTokenProvider) Name() string {
+	return "bearer"
+}
+
+func (bp *BearerTokenProvider) Authenticate(w http.ResponseWriter, r *http.Request) (*register.User, error) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return nil, nil
+	}
+	u, ok := bp.tokens[sha256.Sum256([]byte(strings.TrimSpace(h[7:])))]
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return nil, errors.New("invalid bearer token")
+	}
+	return u, nil
+}
@@ -108,7 +108,8 @@ func (bl *BootstrapLayout) Render(ctx register.PageContext, w http.ResponseWrite
 		Kind:        register.ElementTag_Closing,
 		AttibutingElement: &register.AttibutingElement{
 			Attributes: map[string]interface{}{
-				"src": bl.jsFile,
+				"src":   bl.jsFile,
+				"nonce": ctx.Nonce(),
 				// "integrity":   "sha384-ApNbgh9B+Y1QKtv3Rn7W3mgPxhU9K/ScQsAP7hUibX39j7fakFPskvXusvfa0b4Q",
 				// "crossorigin": "anonymous",
 			},
@@ -66,7 +66,10 @@ type HtmlElement interface {
 
 type HtmlTextElement interface {
 	HtmlNodeElement
+	// AppendText adds text to the element. It is escaped, so it is always shown as text
 	AppendText(string) HtmlTextElement
+	// AppendHTML adds markup to the element exactly as it is. Never use it for text that came from a user
+	AppendHTML(template.HTML) HtmlTextElement
 }
 
 type HtmlNodeElement interface {
@@ -123,7 +126,14 @@ type textnode struct {
 
 func (tn *textnode) AppendText(s string) HtmlTextElement {
 	tn.writableElements = append(tn.writableElements, &textWriter{
-		text: s,
+		text: template.HTMLEscapeString(s),
+	})
+	return tn
+}
+
+func (tn *textnode) AppendHTML(s template.HTML) HtmlTextElement {
+	tn.writableElements = append(tn.writableElements, &textWriter{
+		text: string(s),
 	})
 	return tn
 }
@@ -158,9 +168,7 @@ func (tn *textnode) write(w io.Writer) (bn int64, retErr error) {
 		}
 	}()
 	bn += mustWrite(w, fmt.Sprintf("<%s", tn.name)) // we always write this starting part of the element
-	if tn.id != "" {
-		bn += mustWrite(w, fmt.Sprintf(" ID=\"%s\"", tn.id))
-	}
+	bn += tn.writeAttributes(w)
 
 	bn += mustWrite(w, ">") // close the opening element tag
 	for _, we := range tn.writableElements {
@@ -278,12 +286,7 @@ func (n *node) write(w io.Writer) (bn int64, retErr error) {
 		}
 	}()
 	bn += mustWrite(w, fmt.Sprintf("<%s", n.name)) // we always write this starting part of the element
-	if n.id != "" {
-		bn += mustWrite(w, fmt.Sprintf(" ID=\"%s\"", n.id))
-	}
-	for name, attrib := range n.attribs {
-		bn += mustWrite(w, fmt.Sprintf(" %s=\"%s\"", name, attrib.GetValue()))
-	}
+	bn += n.writeAttributes(w)
 	if len(n.children) == 0 && n.isClosing {
 		// a self-closing node that did not have any child elements
 		bn += mustWrite(w, "/>") // close out the opening tag with an end slash and return as we are done
@@ -303,6 +306,17 @@ func (n *node) write(w io.Writer) (bn int64, retErr error) {
 	return bn, nil
 }
 
+// writeAttributes writes the id and attributes of the node, escaped
+func (n *node) writeAttributes(w io.Writer) (bn int64) {
+	if n.id != "" {
+		bn += mustWrite(w, fmt.Sprintf(" ID=\"%s\"", template.HTMLEscapeString(n.id)))
+	}
+	for name, attrib := range n.attribs {
+		bn += mustWrite(w, fmt.Sprintf(" %s=\"%s\"", template.HTMLEscapeString(name), template.HTMLEscapeString(attrib.GetValue())))
+	}
+	return bn
+}
+
 func mustWrite(w io.Writer, s string) int64 {
 	n, e := w.Write([]byte(s))
 	if e != nil {
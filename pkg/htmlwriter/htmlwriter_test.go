@@ -45,3 +45,16 @@ func TestTextNodeFunctions(t *testing.T) {
 	assert.Equal(t, int64(sb.Len()), n)
 	assert.Equal(t, "<html><body></body>This is a bunch of raw text<p>testvalue</p><span>blah</span><div><span ID=\"12345\"></span></div></html>", sb.String())
 }
+
+func TestEscaping(t *testing.T) {
+	hw := NewHtmlWriter()
+	hw.NewRoot("div").
+		AddTextElement("p", func(hte HtmlTextElement) {
+			hte.SetAttribute("title", &StringAttribute{Value: `"><script>`})
+			hte.AppendText("<b>bold</b>").AppendHTML("<i>italic</i>")
+		})
+	sb := &strings.Builder{}
+	_, err := hw.WriteTo(sb)
+	assert.Nil(t, err)
+	assert.Equal(t, `<div><p title="&#34;&gt;&lt;script&gt;">&lt;b&gt;bold&lt;/b&gt;<i>italic</i></p></div>`, sb.String())
+}
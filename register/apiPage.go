@@ -2,11 +2,14 @@ package register
 
 import (
 	"net/http"
+	"reflect"
 )
 
 type APIPage struct {
 	name   string
 	action func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{}
+	// how a typed api page is called. Nil for pages that handle requests themselves
+	api *apiDescription
 }
 
 func (ap *APIPage) Name() string {
@@ -26,3 +29,69 @@ func NewAPIPage(name string, f func(ctx PageContext, w http.ResponseWriter, r *h
 		action: f,
 	}
 }
+
+type apiDescription struct {
+	method        string
+	successStatus int
+	request       reflect.Type
+	response      reflect.Type
+}
+
+// APIOption changes how a typed api page is called
+type APIOption func(*apiDescription)
+
+// WithMethod sets the http method the api page accepts. The default is GET.
+func WithMethod(method string) APIOption {
+	return func(ad *apiDescription) {
+		ad.method = method
+	}
+}
+
+// WithSuccessStatus sets the status code the api page responds with when it succeeds (i.e: 201). The default is 200.
+func WithSuccessStatus(code int) APIOption {
+	return func(ad *apiDescription) {
+		ad.successStatus = code
+	}
+}
+
+// NewTypedAPIPage creates an api page from a function that takes a request and returns a response. The request is bound from the
+// query string and body (see BindRequest), and the response is written as JSON. Errors are written as {"error": "..."} with the status
+// code of the error (see StatusCodeOf). Typed api pages are described in the OpenAPI document of the site.
+func NewTypedAPIPage[Req interface{}, Resp interface{}](name string, f func(ctx PageContext, req Req) (Resp, error), opts ...APIOption) *APIPage {
+	ad := &apiDescription{
+		method:        http.MethodGet,
+		successStatus: http.StatusOK,
+		request:       reflect.TypeOf((*Req)(nil)).Elem(),
+		response:      reflect.TypeOf((*Resp)(nil)).Elem(),
+	}
+	for _, o := range opts {
+		o(ad)
+	}
+	return &APIPage{
+		name: name,
+		api:  ad,
+		action: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+			if r.Method != ad.method {
+				w.Header().Set("Allow", ad.method)
+				writeJSONError(ctx, w, HTTPErrorf(http.StatusMethodNotAllowed, "%s is not allowed, use %s", r.Method, ad.method))
+				return nil
+			}
+			var req Req
+			if err := ctx.UnmarshallData(&req); err != nil {
+				if StatusCodeOf(err) == http.StatusInternalServerError {
+					// anything wrong with the request itself is the fault of the caller
+					err = NewHTTPError(http.StatusBadRequest, err)
+				}
+				writeJSONError(ctx, w, err)
+				return nil
+			}
+			resp, err := f(ctx, req)
+			if err != nil {
+				writeJSONError(ctx, w, err)
+				return nil
+			}
+			writeJSON(w, ad.successStatus, resp)
+			return nil
+		},
+	}
+}
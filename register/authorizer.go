@@ -1,16 +1,250 @@
 package register
 
-// the authorizer provides a page with authorization capabilities. 
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+)
 
+// the authorizer provides a page with authorization capabilities.
+
+// PageAuthorizer decides if the current request is allowed to access a page.
 type PageAuthorizer interface {
-	IsAuthorized(page Page, ctx PageContext) 
+	IsAuthorized(page Page, ctx PageContext) AuthResult
+}
+
+// AuthorizerFunc allows a simple function to be used as a PageAuthorizer
+type AuthorizerFunc func(page Page, ctx PageContext) AuthResult
+
+func (af AuthorizerFunc) IsAuthorized(page Page, ctx PageContext) AuthResult {
+	return af(page, ctx)
 }
 
 type AuthResult struct {
 	// The reason for the auth failure. If empty, it is assumed successful
 	Reason string
 	// Where to redirect the request to. Leave nil if you want the standard mechanics to handle it
-	Redirect interface{} 
+	Redirect interface{}
 	// Set to true to direct the user to login instead. The request will be redirected to the relevant authorizer with a page rediect on success.
 	RequestAuth bool
-}
\ No newline at end of file
+}
+
+// Authorized returns true if the result allows the page to be accessed
+func (ar AuthResult) Authorized() bool {
+	return ar.Reason == "" && ar.Redirect == nil && !ar.RequestAuth
+}
+
+const (
+	// ReturnUrlParam is the query string parameter given to the signin page so it knows where to send the user once signed in.
+	ReturnUrlParam = "returnTo"
+
+	authResultKey = "GOOEY_authresult"
+)
+
+// Authenticator identifies the user of a request from the request itself (i.e: an Authorization header) rather than from the session.
+// If the request does not carry any credentials the authenticator understands, it should return a nil user and no error. If the credentials
+// are invalid, an error is returned and the request is refused. The authenticator is able to add headers to the refusal.
+type Authenticator interface {
+	Authenticate(w http.ResponseWriter, r *http.Request) (*User, error)
+}
+
+// AddAuthenticator adds an authenticator to the default site
+func AddAuthenticator(a Authenticator) {
+	globalregister.AddAuthenticator(a)
+}
+
+// AddAuthenticator adds an authenticator to the site. Authenticators are evaluated in the order they are added, and the first to identify a user is used.
+func (s *Site) AddAuthenticator(a Authenticator) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.authenticators = append(s.authenticators, a)
+}
+
+// authenticate evaluates the authenticators for the request. Returns false if the request has been refused
+func (s *Site) authenticate(ctx *pageContext, w http.ResponseWriter, r *http.Request) bool {
+	for _, a := range s.authenticators {
+		u, err := a.Authenticate(w, r)
+		if err != nil {
+			logger.WithError(err).Warn("request failed authentication")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return false
+		}
+		if u != nil {
+			ctx.requestUser = u
+			return true
+		}
+	}
+	return true
+}
+
+// AddAuthorizer adds an authorizer to the default site that applies to every page
+func AddAuthorizer(a PageAuthorizer) {
+	globalregister.AddAuthorizer(a)
+}
+
+// AddPageAuthorizer adds an authorizer to a page of the default site
+func AddPageAuthorizer(page Page, a PageAuthorizer) error {
+	return globalregister.AddPageAuthorizer(page, a)
+}
+
+// AddAuthorizer adds an authorizer that applies to every page of the site
+func (s *Site) AddAuthorizer(a PageAuthorizer) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.authorizers = append(s.authorizers, a)
+}
+
+// AddPageAuthorizer adds an authorizer to the given page. The authorizer will also apply to all children of that page.
+// The page must already be registered.
+func (s *Site) AddPageAuthorizer(page Page, a PageAuthorizer) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	info := s.pageRegister[page]
+	if info == nil {
+		return fmt.Errorf("cannot add authorizer: page %s has not been registered", page.Name())
+	}
+	info.authorizers = append(info.authorizers, a)
+	if s.compiled {
+		s.publish()
+	}
+	return nil
+}
+
+// WithAuthorizer adds authorizers to a page as it is registered. They also apply to all children of the page.
+func WithAuthorizer(a ...PageAuthorizer) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.authorizers = append(info.authorizers, a...)
+	})
+}
+
+// RequireRoles only allows users that hold at least one of the given roles to access the page and its children.
+func RequireRoles(roles ...string) PageOption {
+	return WithAuthorizer(RoleAuthorizer(roles...))
+}
+
+// RoleAuthorizer only authorizes users that hold at least one of the given roles. Anonymous users are asked to sign in.
+func RoleAuthorizer(roles ...string) PageAuthorizer {
+	return AuthorizerFunc(func(page Page, ctx PageContext) AuthResult {
+		u := ctx.User()
+		if u == nil {
+			return AuthResult{RequestAuth: true}
+		}
+		for _, r := range roles {
+			if u.HasRole(r) {
+				return AuthResult{}
+			}
+		}
+		return AuthResult{Reason: fmt.Sprintf("this requires the %s role", strings.Join(roles, " or "))}
+	})
+}
+
+// authorize evaluates all authorizers that apply to the page. Page authorizers are checked before its parents, and the global authorizers are checked last.
+// The signin page (and anything beneath it) is always accessible.
+func (s *Site) authorize(page Page, ctx PageContext) AuthResult {
+	info := s.treeFor(ctx).pages[page]
+	for curr := info; curr != nil; curr = curr.resolvedParent {
+		if curr.id == SigninPageId {
+			return AuthResult{}
+		}
+	}
+	for curr := info; curr != nil; curr = curr.resolvedParent {
+		for _, a := range curr.authorizers {
+			if res := a.IsAuthorized(page, ctx); !res.Authorized() {
+				return res
+			}
+		}
+	}
+	for _, a := range s.authorizers {
+		if res := a.IsAuthorized(page, ctx); !res.Authorized() {
+			return res
+		}
+	}
+	return AuthResult{}
+}
+
+// handleAuthFailure responds to a request that was not authorized
+func (s *Site) handleAuthFailure(ctx *pageContext, w http.ResponseWriter, r *http.Request, res AuthResult) {
+	ctx.RequestCache().SetValue(authResultKey, &res)
+	if res.Redirect != nil {
+		u, err := ctx.ResolveUrl(res.Redirect)
+		if err == nil {
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+		logger.WithError(err).Error("failed to resolve authorization redirect")
+	}
+	if res.RequestAuth {
+		if signin := s.treeFor(ctx).registered[SigninPageId]; signin != nil {
+			u := ctx.GetPageUrl(signin.page)
+			q := u.Query()
+			q.Set(ReturnUrlParam, r.URL.RequestURI())
+			u.RawQuery = q.Encode()
+			http.Redirect(w, r, u.String(), http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	} else {
+		w.WriteHeader(http.StatusForbidden)
+	}
+	forbidden := s.corePages.ForbiddenPage
+	if forbidden == nil {
+		forbidden = &forbiddenPage{}
+	}
+	s.renderPage(ctx, w, r, forbidden)
+}
+
+// GetAuthFailure returns the reason the current request was refused access. Returns nil if access was not refused.
+func GetAuthFailure(ctx PageContext) *AuthResult {
+	if v, ok := ctx.RequestCache().GetValue(authResultKey); ok {
+		return v.(*AuthResult)
+	}
+	return nil
+}
+
+// ReturnUrl returns the url a signin page should send the user back to once they have signed in. Only urls within the site are allowed,
+// otherwise the site root is returned.
+func ReturnUrl(ctx PageContext) *url.URL {
+	var target string
+	if v := ctx.GetContextData()[ReturnUrlParam]; len(v) > 0 {
+		target = v[0]
+	}
+	return ResolveReturnUrl(ctx, target)
+}
+
+// ResolveReturnUrl turns a path relative to the root of the site (as given to the signin page) into a full url. Only urls within the site
+// are allowed, otherwise the site root is returned.
+func ResolveReturnUrl(ctx PageContext, target string) *url.URL {
+	root := ctx.GetPageUrl(ctx.SiteRoot().Page())
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return root
+	}
+	// the target is relative to the root of the site
+	u, err := root.Parse("./" + strings.TrimPrefix(target, "/"))
+	if err != nil {
+		return root
+	}
+	return u
+}
+
+// RedirectToReturnUrl completes a signin by sending the user back to the page they originally requested.
+func RedirectToReturnUrl(ctx PageContext, w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, ReturnUrl(ctx).String(), http.StatusSeeOther)
+}
+
+type forbiddenPage struct {
+}
+
+func (fp *forbiddenPage) Name() string {
+	return "Forbidden"
+}
+
+func (fp *forbiddenPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	reason := "You do not have access to this page"
+	if res := GetAuthFailure(ctx); res != nil && res.Reason != "" {
+		reason = res.Reason
+	}
+	fmt.Fprintf(w, `<div class="GOOEY GOOEY_forbidden">%s</div>`, html.EscapeString(reason))
+	return nil
+}
@@ -0,0 +1,100 @@
+package register
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Authorizers(t *testing.T) {
+	site := NewSite()
+	root := &testPage{name: "root"}
+	secret := &testPage{name: "secret"}
+	secretChild := &testPage{name: "secretchild"}
+	members := &testPage{name: "members"}
+	site.RegisterPage(nil, RootPageId, root)
+	site.RegisterPage(RootPageId, "secret", secret)
+	site.RegisterPage(secret, "child", secretChild)
+	site.RegisterPage(RootPageId, "members", members)
+	site.RegisterPage(RootPageId, SigninPageId, &testPage{name: "signin"})
+
+	assert.NoError(t, site.AddPageAuthorizer(secret, AuthorizerFunc(func(page Page, ctx PageContext) AuthResult {
+		return AuthResult{Reason: "<not for you>"}
+	})))
+	site.AddAuthorizer(AuthorizerFunc(func(page Page, ctx PageContext) AuthResult {
+		if page == members && len(ctx.GetContextData()["member"]) == 0 {
+			return AuthResult{RequestAuth: true}
+		}
+		return AuthResult{}
+	}))
+	assert.Error(t, site.AddPageAuthorizer(&testPage{name: "unregistered"}, nil))
+	h := site.Handler()
+
+	code, body := getBody(t, h, "/secret")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "&lt;not for you&gt;")
+	assert.NotContains(t, body, "page:secret")
+
+	// authorizers are inherited by child pages
+	code, _ = getBody(t, h, "/secret/child")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	// pages requesting authentication are sent to the signin page, and back again
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/members?x=1", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	loc := rec.Header().Get("Location")
+	assert.Equal(t, "http://example.com/signin?returnTo=%2Fmembers%3Fx%3D1", loc)
+
+	code, body = getBody(t, h, "/members?member=yes")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "page:members")
+
+	ctx := newPageContext(site, site.ctx, httptest.NewRequest(http.MethodGet, "/signin?returnTo=%2Fmembers%3Fx%3D1", nil))
+	assert.Equal(t, "http://example.com/members?x=1", ReturnUrl(ctx).String())
+	for _, bad := range []string{"https://evil.com", "//evil.com", "/\\evil.com", "javascript:alert(1)"} {
+		ctx := newPageContext(site, site.ctx, httptest.NewRequest(http.MethodGet, "/signin?returnTo="+bad, nil))
+		assert.Equal(t, "example.com", ReturnUrl(ctx).Host, bad)
+	}
+
+	// the nav hides pages that cannot be opened
+	var names []string
+	for _, c := range ctx.SiteRoot().Children() {
+		names = append(names, c.Title())
+	}
+	assert.ElementsMatch(t, []string{"signin"}, names)
+}
+
+// headerAuthenticator signs the request in as a user holding the roles in the X-Roles header
+type headerAuthenticator struct {
+}
+
+func (ha *headerAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*User, error) {
+	if roles := r.Header.Get("X-Roles"); roles != "" {
+		return &User{Id: "u", Roles: strings.Split(roles, ",")}, nil
+	}
+	return nil, nil
+}
+
+func Test_RequireRoles(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "ops", &testPage{name: "ops"}, RequireRoles("operator", "admin"))
+	site.AddAuthenticator(&headerAuthenticator{})
+	h := site.Handler()
+
+	request := func(roles string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/ops", nil)
+		r.Header.Set("X-Roles", roles)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+	// there is no signin page, so anonymous users are simply refused
+	assert.Equal(t, http.StatusUnauthorized, request("").Code)
+	assert.Equal(t, http.StatusForbidden, request("viewer").Code)
+	assert.Equal(t, http.StatusOK, request("viewer,admin").Code)
+}
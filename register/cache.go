@@ -1,10 +1,47 @@
 package register
 
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
 type Cache interface {
 	GetValue(key string) (interface{}, bool)
 	SetValue(key string, val interface{})
 }
 
+// SharedCache is a Cache that is safe to use from many requests at once. Values expire after the TTL of the cache, and once the cache is
+// full the least recently used values are dropped.
+type SharedCache interface {
+	Cache
+	// SetValueTTL stores the value for the given time rather than the TTL of the cache. A TTL of 0 never expires.
+	SetValueTTL(key string, val interface{}, ttl time.Duration)
+	// GetOrCompute returns the value for the key, calling compute to create it if it is missing. If several requests ask for the same
+	// missing key at once, compute is only called once and they all get the result. Errors are returned but not cached.
+	GetOrCompute(key string, compute func() (interface{}, error)) (interface{}, error)
+	// Invalidate removes the value for the key
+	Invalidate(key string)
+	// InvalidatePrefix removes every value whose key starts with the prefix
+	InvalidatePrefix(prefix string)
+	// Clear removes everything
+	Clear()
+}
+
+// CacheOptions control how long values are kept in a SharedCache
+type CacheOptions struct {
+	// How long values are kept. 0 keeps them until they are evicted
+	TTL time.Duration
+	// The most values that will be kept. 0 has no limit
+	MaxEntries int
+}
+
+var (
+	defaultAppCacheOptions     = CacheOptions{TTL: 5 * time.Minute, MaxEntries: 10000}
+	defaultSessionCacheOptions = CacheOptions{TTL: 5 * time.Minute, MaxEntries: 100}
+)
+
 type memoryCache struct {
 	data map[string]interface{}
 }
@@ -22,3 +59,191 @@ func (c *memoryCache) GetValue(key string) (interface{}, bool) {
 func (c *memoryCache) SetValue(key string, val interface{}) {
 	c.data[key] = val
 }
+
+type cacheEntry struct {
+	key     string
+	val     interface{}
+	expires time.Time
+}
+
+// an in flight call to compute a value
+type cacheCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+type syncCache struct {
+	opts    CacheOptions
+	mux     sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// invalidating a key drops its call from here, so the value being computed is not stored once it is done
+	inflight map[string]*cacheCall
+	now      func() time.Time
+}
+
+// NewCache creates a SharedCache kept in memory
+func NewCache(opts CacheOptions) SharedCache {
+	return newSyncCache(opts)
+}
+
+func newSyncCache(opts CacheOptions) *syncCache {
+	return &syncCache{
+		opts:     opts,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*cacheCall{},
+		now:      time.Now,
+	}
+}
+
+func (c *syncCache) GetValue(key string) (interface{}, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.get(key)
+}
+
+// get must be called holding the lock
+func (c *syncCache) get(key string) (interface{}, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	if !e.expires.IsZero() && !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e.val, true
+}
+
+func (c *syncCache) SetValue(key string, val interface{}) {
+	c.SetValueTTL(key, val, c.opts.TTL)
+}
+
+func (c *syncCache) SetValueTTL(key string, val interface{}, ttl time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.set(key, val, ttl)
+}
+
+// set must be called holding the lock
+func (c *syncCache) set(key string, val interface{}, ttl time.Duration) {
+	e := &cacheEntry{key: key, val: val}
+	if ttl > 0 {
+		e.expires = c.now().Add(ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	for c.opts.MaxEntries > 0 && c.lru.Len() > c.opts.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *syncCache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+func (c *syncCache) GetOrCompute(key string, compute func() (interface{}, error)) (interface{}, error) {
+	c.mux.Lock()
+	if v, ok := c.get(key); ok {
+		c.mux.Unlock()
+		return v, nil
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mux.Unlock()
+		<-call.done
+		return call.val, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.mux.Unlock()
+
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				call.err = Recovered(rec)
+			}
+		}()
+		call.val, call.err = compute()
+	}()
+
+	c.mux.Lock()
+	if c.inflight[key] == call {
+		delete(c.inflight, key)
+		if call.err == nil {
+			c.set(key, call.val, c.opts.TTL)
+		}
+	}
+	c.mux.Unlock()
+	close(call.done)
+	return call.val, call.err
+}
+
+func (c *syncCache) Invalidate(key string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	delete(c.inflight, key)
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *syncCache) InvalidatePrefix(prefix string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for k := range c.inflight {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.inflight, k)
+		}
+	}
+	for k, el := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *syncCache) Clear() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.inflight = map[string]*cacheCall{}
+	c.entries = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// SetCacheOptions configures the caches of the default site
+func SetCacheOptions(app, session CacheOptions) {
+	globalregister.SetCacheOptions(app, session)
+}
+
+// SetCacheOptions configures the application cache, and the cache each session gets. Anything already cached is dropped.
+func (s *Site) SetCacheOptions(app, session CacheOptions) {
+	s.appCache = newSyncCache(app)
+	s.sessionCacheOptions = session
+	s.sessionCaches = newSessionCaches(s.sessions.opts)
+}
+
+// the caches of each session, by session id. A session cannot outlive its absolute timeout, so neither can its cache. The least recently
+// used sessions are dropped first when there are too many.
+func newSessionCaches(opts SessionOptions) *syncCache {
+	return newSyncCache(CacheOptions{TTL: opts.AbsoluteTimeout, MaxEntries: 10000})
+}
+
+// sessionCache returns the cache for the session, creating it if needed. The session has to be kept for the cache to be found again, so
+// a session that has never been saved (i.e: an anonymous browser) is from now on. That needs the browser to be given the session cookie,
+// which can't happen once a streamed page has started to be sent.
+func (s *Site) sessionCache(sess *Session) SharedCache {
+	sess.persist()
+	v, _ := s.sessionCaches.GetOrCompute(sess.Id(), func() (interface{}, error) {
+		return newSyncCache(s.sessionCacheOptions), nil
+	})
+	return v.(SharedCache)
+}
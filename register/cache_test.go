@@ -0,0 +1,179 @@
+package register
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SharedCache(t *testing.T) {
+	c := newSyncCache(CacheOptions{TTL: time.Minute, MaxEntries: 2})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.SetValue("a", 1)
+	c.SetValue("b", 2)
+	c.GetValue("a")
+	c.SetValue("c", 3)
+	// b was the least recently used
+	_, ok := c.GetValue("b")
+	assert.False(t, ok)
+	v, ok := c.GetValue("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.SetValueTTL("forever", "x", 0)
+	now = now.Add(2 * time.Minute)
+	_, ok = c.GetValue("c")
+	assert.False(t, ok)
+	_, ok = c.GetValue("forever")
+	assert.True(t, ok)
+
+	c.SetValue("buckets.eu", 1)
+	c.SetValue("buckets.us", 2)
+	c.InvalidatePrefix("buckets.")
+	_, ok = c.GetValue("buckets.us")
+	assert.False(t, ok)
+	c.Clear()
+	_, ok = c.GetValue("forever")
+	assert.False(t, ok)
+}
+
+func Test_SharedCacheGetOrCompute(t *testing.T) {
+	c := newSyncCache(defaultAppCacheOptions)
+	var calls int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrCompute("buckets", func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				<-release
+				return []string{"logs"}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"logs"}, v)
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls)
+
+	// errors are not cached
+	_, err := c.GetOrCompute("broken", func() (interface{}, error) { return nil, errors.New("nope") })
+	assert.Error(t, err)
+	v, err := c.GetOrCompute("broken", func() (interface{}, error) { return "fixed", nil })
+	assert.NoError(t, err)
+	assert.Equal(t, "fixed", v)
+
+	c.Invalidate("buckets")
+	c.GetOrCompute("buckets", func() (interface{}, error) { atomic.AddInt32(&calls, 1); return nil, nil })
+	assert.Equal(t, int32(2), calls)
+}
+
+func Test_SharedCacheInvalidateComputing(t *testing.T) {
+	c := newSyncCache(defaultAppCacheOptions)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, key := range []string{"buckets", "workers"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			c.GetOrCompute(key, func() (interface{}, error) {
+				started <- struct{}{}
+				<-release
+				return "old " + key, nil
+			})
+		}(key)
+	}
+	<-started
+	<-started
+	// only the value being computed for the invalidated key is thrown away
+	c.Invalidate("buckets")
+	close(release)
+	wg.Wait()
+	_, ok := c.GetValue("buckets")
+	assert.False(t, ok)
+	v, ok := c.GetValue("workers")
+	assert.True(t, ok)
+	assert.Equal(t, "old workers", v)
+}
+
+func Test_SessionCache(t *testing.T) {
+	site := NewSite()
+	// the session is only used as the page is written, so the cookie can only be sent if the page is buffered
+	site.SetRenderMode(RenderBuffered)
+	site.RegisterPage(nil, RootPageId, &funcPage{name: "root", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		v, _ := ctx.SessionCache().GetOrCompute("visits", func() (interface{}, error) { return new(int32), nil })
+		w.Header().Set("X-Visits", strconv.Itoa(int(atomic.AddInt32(v.(*int32), 1))))
+		if r.URL.Query().Has("logout") {
+			ctx.Session().Destroy()
+		} else {
+			ctx.Session().Set("seen", "yes")
+		}
+		ctx.AppCache().SetValue("last", r.URL.Path)
+		return nil
+	}})
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "1", rec.Header().Get("X-Visits"))
+	cookies := rec.Result().Cookies()
+
+	visit := func(path string) string {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Header().Get("X-Visits")
+	}
+	assert.Equal(t, "2", visit("/"))
+	assert.Equal(t, "3", visit("/?logout"))
+	// the cache went with the session
+	assert.Equal(t, "1", visit("/"))
+
+	// a different browser has its own cache
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "1", rec.Header().Get("X-Visits"))
+	v, _ := site.appCache.GetValue("last")
+	assert.Equal(t, "/", v)
+}
+
+func Test_SessionCacheAnonymous(t *testing.T) {
+	site := NewSite()
+	site.SetSessionOptions(SessionOptions{AbsoluteTimeout: time.Minute})
+	assert.Equal(t, time.Minute, site.sessionCaches.opts.TTL)
+	site.SetRenderMode(RenderBuffered)
+	site.RegisterPage(nil, RootPageId, &funcPage{name: "root", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		// nothing else is stored in the session
+		v, _ := ctx.SessionCache().GetOrCompute("visits", func() (interface{}, error) { return new(int32), nil })
+		w.Header().Set("X-Visits", strconv.Itoa(int(atomic.AddInt32(v.(*int32), 1))))
+		return nil
+	}})
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	assert.Equal(t, "2", rec.Header().Get("X-Visits"))
+}
@@ -0,0 +1,200 @@
+package register
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CompileProblemKind identifies the kind of problem found while compiling a site
+type CompileProblemKind string
+
+const (
+	// The page was registered without a parent, so it cannot be reached
+	ProblemOrphan = CompileProblemKind("orphan")
+	// The parent of the page was never registered
+	ProblemUnknownParent = CompileProblemKind("unknown parent")
+	// The page is its own ancestor
+	ProblemCycle = CompileProblemKind("cycle")
+	// More than one page was registered with the same id
+	ProblemDuplicateId = CompileProblemKind("duplicate id")
+	// The path of the page is already handled by another page
+	ProblemPathCollision = CompileProblemKind("path collision")
+)
+
+// CompileProblem is a single problem found with the page hierarchy
+type CompileProblem struct {
+	Kind   CompileProblemKind
+	PageId string
+	Detail string
+}
+
+func (cp CompileProblem) String() string {
+	return fmt.Sprintf("%s: page %s: %s", cp.Kind, cp.PageId, cp.Detail)
+}
+
+// CompileError is returned when the page hierarchy has problems. All problems found are reported, not just the first.
+type CompileError struct {
+	Problems []CompileProblem
+}
+
+func (ce *CompileError) Error() string {
+	lines := make([]string, 0, len(ce.Problems))
+	for _, p := range ce.Problems {
+		lines = append(lines, p.String())
+	}
+	return fmt.Sprintf("site has %d problem(s):\n\t%s", len(ce.Problems), strings.Join(lines, "\n\t"))
+}
+
+// Of returns the problems of the given kind
+func (ce *CompileError) Of(kind CompileProblemKind) []CompileProblem {
+	var ret []CompileProblem
+	for _, p := range ce.Problems {
+		if p.Kind == kind {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// CompileOption changes how a site is compiled
+type CompileOption func(*compileOptions)
+
+type compileOptions struct {
+	orphansToRoot bool
+}
+
+// OrphansToRoot places pages that were registered without a parent beneath the root page instead of reporting them as problems.
+func OrphansToRoot() CompileOption {
+	return func(co *compileOptions) {
+		co.orphansToRoot = true
+	}
+}
+
+// Compile the pages of the default site into the required hierachy
+func Compile(opts ...CompileOption) error {
+	return globalregister.Compile(opts...)
+}
+
+// Compile the pages into the required hierachy. If there are problems with the hierarchy, a *CompileError is returned listing all of them.
+// The site will still serve the pages that could be placed.
+func (s *Site) Compile(opts ...CompileOption) error {
+	s.precompileCheck()
+	var co compileOptions
+	for _, o := range opts {
+		o(&co)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.compileOptions = co
+	problems := append([]CompileProblem{}, s.registrationProblems...)
+	s.registrationProblems = nil
+	problems = append(problems, s.compile()...)
+	if len(problems) > 0 {
+		return &CompileError{Problems: problems}
+	}
+	return nil
+}
+
+// compile places any queued pages into the hierarchy, then rebuilds and publishes the routes. s.mux must be held.
+func (s *Site) compile() []CompileProblem {
+	var problems []CompileProblem
+	queued := sortedPages(s.queued)
+	for _, info := range queued {
+		s.registered[info.id] = info
+	}
+	s.queued = map[string]*registeredPageInfo{}
+	for _, info := range queued {
+		parent, problem := s.resolveParent(info, s.compileOptions)
+		if problem != nil {
+			problems = append(problems, *problem)
+			continue
+		}
+		parent.children[info.id] = info
+		info.resolvedParent = parent
+		logger.WithField("Id", info.id).WithField("ParentId", parent.id).Info("Page hierachy established")
+	}
+	problems = append(problems, s.findCycles()...)
+
+	routes, routeProblems := s.buildRoutes()
+	problems = append(problems, routeProblems...)
+	s.routes = routes
+	s.publish()
+	s.compiled = true
+	return problems
+}
+
+func (s *Site) resolveParent(info *registeredPageInfo, co compileOptions) (*registeredPageInfo, *CompileProblem) {
+	var parent *registeredPageInfo
+	switch pid := info.parentid.(type) {
+	case nil:
+		if co.orphansToRoot {
+			return s.root, nil
+		}
+		return nil, &CompileProblem{Kind: ProblemOrphan, PageId: info.id, Detail: "no parent was given"}
+	case string:
+		if parent = s.registered[normalizePageId(pid)]; parent == nil {
+			return nil, &CompileProblem{Kind: ProblemUnknownParent, PageId: info.id, Detail: fmt.Sprintf("parent id %s has not been registered", pid)}
+		}
+	case Page:
+		if parent = s.pageRegister[pid]; parent == nil {
+			return nil, &CompileProblem{Kind: ProblemUnknownParent, PageId: info.id, Detail: fmt.Sprintf("parent page %s has not been registered", pid.Name())}
+		}
+	}
+	return parent, nil
+}
+
+// findCycles reports pages whose ancestors lead back to themselves. These can never be reached from the root.
+func (s *Site) findCycles() []CompileProblem {
+	var problems []CompileProblem
+	reported := map[*registeredPageInfo]bool{}
+	for _, info := range sortedPages(s.registered) {
+		seen := map[*registeredPageInfo]bool{}
+		curr := info
+		for curr != nil && !seen[curr] && !reported[curr] {
+			seen[curr] = true
+			curr = curr.resolvedParent
+		}
+		if curr == nil || reported[curr] {
+			continue
+		}
+		// curr is part of a cycle. Walk it once to report it
+		var ids []string
+		for c := curr; !reported[c]; c = c.resolvedParent {
+			reported[c] = true
+			ids = append(ids, c.id)
+		}
+		for _, c := range ids {
+			// break the cycle so that walking the hierarchy terminates
+			if p := s.registered[c]; p.resolvedParent != nil {
+				delete(p.resolvedParent.children, c)
+				p.resolvedParent = nil
+			}
+		}
+		problems = append(problems, CompileProblem{Kind: ProblemCycle, PageId: ids[0], Detail: strings.Join(append(ids, ids[0]), " -> ")})
+	}
+	return problems
+}
+
+// registrationOrder returns the pages in the order they were registered. Routes are added in this order so that when paths collide,
+// the page that was registered first keeps its path, even if the other page was added while the site is running.
+func registrationOrder(pages map[string]*registeredPageInfo) []*registeredPageInfo {
+	ret := sortedPages(pages)
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].seq < ret[j].seq
+	})
+	return ret
+}
+
+// sortedPages returns the pages ordered by id, so problems and routes are consistent between runs
+func sortedPages(pages map[string]*registeredPageInfo) []*registeredPageInfo {
+	ret := make([]*registeredPageInfo, 0, len(pages))
+	for _, p := range pages {
+		ret = append(ret, p)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].id < ret[j].id
+	})
+	return ret
+}
@@ -0,0 +1,57 @@
+package register
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CompileProblems(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	assert.Error(t, site.RegisterPage(nil, RootPageId, &testPage{name: "root2"}))
+	site.RegisterPage(RootPageId, "ok", &testPage{name: "ok"})
+	assert.Error(t, site.RegisterPage(RootPageId, "OK", &testPage{name: "ok2"}))
+	assert.Error(t, site.RegisterPage(42, "badparent", &testPage{name: "badparent"}))
+	site.RegisterPage(nil, "orphan", &testPage{name: "orphan"})
+	site.RegisterPage("missing", "lost", &testPage{name: "lost"})
+	site.RegisterPage(&testPage{name: "unregistered"}, "lost2", &testPage{name: "lost2"})
+	site.RegisterPage("b", "a", &testPage{name: "a"})
+	site.RegisterPage("a", "b", &testPage{name: "b"})
+	site.RegisterPage("ok", "{id}", &testPage{name: "id"})
+	site.RegisterPage("ok", "{other}", &testPage{name: "other"})
+
+	err := site.Compile()
+	var ce *CompileError
+	assert.True(t, errors.As(err, &ce))
+	ids := func(kind CompileProblemKind) (ret []string) {
+		for _, p := range ce.Of(kind) {
+			ret = append(ret, p.PageId)
+		}
+		return
+	}
+	assert.Equal(t, []string{"root", "ok"}, ids(ProblemDuplicateId))
+	assert.Equal(t, []string{"orphan"}, ids(ProblemOrphan))
+	assert.Equal(t, []string{"badparent", "lost", "lost2"}, ids(ProblemUnknownParent))
+	assert.Equal(t, []string{"a"}, ids(ProblemCycle))
+	assert.Equal(t, "a -> b -> a", ce.Of(ProblemCycle)[0].Detail)
+	assert.Equal(t, []string{"{other}"}, ids(ProblemPathCollision))
+	assert.Contains(t, err.Error(), "site has 8 problem(s)")
+
+	// the pages that could be placed are still served
+	code, body := getBody(t, site.Handler(), "/ok")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "page:ok")
+}
+
+func Test_CompileOrphansToRoot(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(nil, "orphan", &testPage{name: "orphan"})
+	assert.NoError(t, site.Compile(OrphansToRoot()))
+
+	_, body := getBody(t, site.Handler(), "/orphan")
+	assert.Contains(t, body, "page:orphan")
+}
@@ -0,0 +1,86 @@
+package register
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"net/http"
+)
+
+const (
+	// CSRFFieldName is the form field the token is sent in
+	CSRFFieldName = "GOOEY_csrf"
+	// CSRFHeaderName is the header scripts can send the token in instead
+	CSRFHeaderName = "X-CSRF-Token"
+	csrfSessionKey = "GOOEY_csrf"
+)
+
+// CSRFExempt turns off the CSRF check for the page. Use this for API pages that are called by other systems rather than from a browser
+// session, as the browser is what makes a forged request dangerous. Requests signed in by an Authenticator, or without a session cookie,
+// are never checked.
+func CSRFExempt() PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.csrfExempt = true
+	})
+}
+
+// CSRFToken returns the token of the session that has to be sent with any request that changes something (POST, PUT, PATCH or DELETE).
+// The site refuses those requests without it, unless the page is CSRFExempt.
+// The token is kept in the session, which a new visitor is only given once the token is stored. If the page is streamed, call this
+// before anything is written (i.e: in QueryBehaviour) so the session cookie can still be sent.
+func CSRFToken(ctx PageContext) string {
+	sess := ctx.Session()
+	if t, ok := sess.Get(csrfSessionKey); ok {
+		return t
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	t := base64.RawURLEncoding.EncodeToString(b)
+	sess.Set(csrfSessionKey, t)
+	return t
+}
+
+// CSRFField returns the hidden input that carries the token in a form
+func CSRFField(ctx PageContext) template.HTML {
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`, CSRFFieldName, html.EscapeString(CSRFToken(ctx))))
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// verifyCSRF returns true if the request does not need a token or has the right one. A forged request relies on the browser sending the
+// session cookie, so requests that identify themselves another way (i.e: a bearer token) or carry no session don't need one.
+func (s *Site) verifyCSRF(ctx *pageContext, r *http.Request, page Page) bool {
+	if isSafeMethod(r.Method) || ctx.requestUser != nil {
+		return true
+	}
+	if _, err := r.Cookie(s.sessions.opts.CookieName); err != nil {
+		return true
+	}
+	if info := s.treeFor(ctx).pages[page]; info != nil && info.csrfExempt {
+		return true
+	}
+	expected, ok := ctx.Session().Get(csrfSessionKey)
+	if !ok {
+		return false
+	}
+	got := r.Header.Get(CSRFHeaderName)
+	if got == "" {
+		// multipart forms are parsed with the same limit the binder uses, so the form is still there for the page
+		if err := r.ParseMultipartForm(maxMultipartMemory); err != nil && err != http.ErrNotMultipart {
+			return false
+		}
+		got = r.PostForm.Get(CSRFFieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(expected)) == 1
+}
@@ -0,0 +1,59 @@
+package register
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CSRF(t *testing.T) {
+	site := NewSite()
+	site.SetSessionOptions(SessionOptions{SameSite: http.SameSiteStrictMode, SecureCookie: true})
+	// the token is only created as the page is written, so the cookie can only be sent if the page is buffered
+	site.SetRenderMode(RenderBuffered)
+	changed := 0
+	change := func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		if r.Method == http.MethodPost {
+			changed++
+		}
+		io.WriteString(w, "token:"+CSRFToken(ctx)+";")
+		return nil
+	}
+	site.RegisterPage(nil, RootPageId, &funcPage{name: "root", f: change})
+	site.RegisterPage(RootPageId, "hook", &funcPage{name: "hook", f: change}, CSRFExempt())
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.True(t, cookies[0].Secure)
+	token := strings.SplitN(strings.SplitN(rec.Body.String(), "token:", 2)[1], ";", 2)[0]
+
+	post := func(target string, form url.Values, header string) int {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if header != "" {
+			r.Header.Set(CSRFHeaderName, header)
+		}
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusForbidden, post("/", url.Values{}, ""))
+	assert.Equal(t, http.StatusForbidden, post("/", url.Values{CSRFFieldName: {"forged"}}, ""))
+	assert.Equal(t, 0, changed)
+	assert.Equal(t, http.StatusOK, post("/", url.Values{CSRFFieldName: {token}}, ""))
+	assert.Equal(t, http.StatusOK, post("/", url.Values{}, token))
+	assert.Equal(t, http.StatusOK, post("/hook", url.Values{}, ""))
+	assert.Equal(t, 3, changed)
+}
@@ -0,0 +1,93 @@
+package register
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DataPage is a page that can provide the data it shows as well as rendering it. When a request asks for JSON (either with an Accept
+// header of application/json or ?format=json), the data is returned instead of the page. Other pages are always rendered as they are.
+type DataPage interface {
+	Page
+	Data(ctx PageContext) (interface{}, error)
+}
+
+const formatParam = "format"
+
+// WantsJSON returns true if the request would rather have JSON than HTML
+func WantsJSON(r *http.Request) bool {
+	if f := r.URL.Query().Get(formatParam); f != "" {
+		return strings.EqualFold(f, "json")
+	}
+	var jsonQ, htmlQ float64
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch {
+		case mt == "application/json" || strings.HasSuffix(mt, "+json"):
+			if q > jsonQ {
+				jsonQ = q
+			}
+		case mt == "text/html" || mt == "application/xhtml+xml":
+			if q > htmlQ {
+				htmlQ = q
+			}
+		}
+	}
+	return jsonQ > 0 && jsonQ > htmlQ
+}
+
+// renderData writes the data of the page as JSON
+func (wr *Site) renderData(ctx *pageContext, w http.ResponseWriter, r *http.Request, dp DataPage) {
+	data, err := dp.Data(ctx)
+	if err != nil {
+		writeJSONError(ctx, w, fmt.Errorf("failed to get the data of page %s: %w", dp.Name(), err))
+		return
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+type jsonError struct {
+	Error string `json:"error"`
+	// the problem with each field of the request that could not be bound
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// writeJSONError writes the error with the status code it should be reported with. Like the error page, the message of an internal
+// error is only given in dev mode.
+func writeJSONError(ctx PageContext, w http.ResponseWriter, err error) {
+	code := StatusCodeOf(err)
+	if code >= http.StatusInternalServerError {
+		logger.WithError(err).Error("request failed")
+	}
+	body := jsonError{Error: ErrorMessage(ctx, err)}
+	var be *BindError
+	if errors.As(err, &be) {
+		body.Fields = map[string]string{}
+		for _, fe := range be.Fields {
+			body.Fields[fe.Field] = fe.Err.Error()
+		}
+	}
+	writeJSON(w, code, body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.WithError(err).Error("failed to write json")
+	}
+}
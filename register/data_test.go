@@ -0,0 +1,62 @@
+package register
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WantsJSON(t *testing.T) {
+	for target, accept := range map[string]string{
+		"/?format=json": "",
+		"/":             "application/json",
+		"/a":            "application/problem+json",
+		"/b":            "text/html;q=0.5, application/json",
+	} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", accept)
+		assert.True(t, WantsJSON(r), "%s %s", target, accept)
+	}
+	for target, accept := range map[string]string{
+		"/?format=html": "application/json",
+		"/":             "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		"/a":            "",
+		"/b":            "*/*",
+	} {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("Accept", accept)
+		assert.False(t, WantsJSON(r), "%s %s", target, accept)
+	}
+
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	// pages without data are rendered as normal
+	_, body := getBody(t, site.Handler(), "/?format=json")
+	assert.Contains(t, body, "page:root")
+}
+
+type dataTestPage struct {
+	testPage
+}
+
+func (dp *dataTestPage) Data(ctx PageContext) (interface{}, error) {
+	return map[string]int{"total": 1}, nil
+}
+
+func Test_DataOnlyForReads(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &dataTestPage{testPage{name: "root"}}, CSRFExempt())
+	h := site.Handler()
+
+	_, body := getBody(t, h, "/?format=json")
+	assert.JSONEq(t, `{"total":1}`, body)
+
+	// a post is always given to the page, whatever the response is asked to be
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/?format=json", nil)
+	r.Header.Set("Accept", "application/json")
+	h.ServeHTTP(rec, r)
+	assert.Contains(t, rec.Body.String(), "page:root")
+}
@@ -0,0 +1,136 @@
+package register
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Pages can be added and removed while the site is serving requests (i.e: a page per discovered consumer or plugin).
+// Registration always works against the site's own copy of the hierarchy under s.mux. Once a change has been made, an immutable
+// snapshot of that hierarchy is published and requests are served from it. A request keeps the snapshot it started with, so the
+// routes, the nav and SiteRoot() it sees are always consistent with each other, even if pages change part way through.
+
+// siteTree is a published snapshot of the page hierarchy. Nothing in it is modified once it has been published.
+type siteTree struct {
+	root       *registeredPageInfo
+	registered map[string]*registeredPageInfo
+	pages      map[Page]*registeredPageInfo
+	routes     *router
+}
+
+// currentTree returns the latest published snapshot. Before the site is compiled, the hierarchy is still being set up and is used as is.
+func (s *Site) currentTree() *siteTree {
+	if t := s.tree.Load(); t != nil {
+		return t
+	}
+	return &siteTree{
+		root:       s.root,
+		registered: s.registered,
+		pages:      s.pageRegister,
+		routes:     s.routes,
+	}
+}
+
+// treeFor returns the snapshot the request is being served from
+func (s *Site) treeFor(ctx PageContext) *siteTree {
+	if pc, ok := ctx.(*pageContext); ok && pc.tree != nil {
+		return pc.tree
+	}
+	return s.currentTree()
+}
+
+// publish copies the hierarchy into a new snapshot and makes it the one new requests are served from. s.mux must be held.
+func (s *Site) publish() {
+	clones := make(map[*registeredPageInfo]*registeredPageInfo, len(s.registered))
+	for _, info := range s.registered {
+		c := *info
+		c.preprocessors = append([]*pagePreprocessor(nil), info.preprocessors...)
+		c.authorizers = append([]PageAuthorizer(nil), info.authorizers...)
+		clones[info] = &c
+	}
+	tree := &siteTree{
+		root:       clones[s.root],
+		registered: make(map[string]*registeredPageInfo, len(clones)),
+		pages:      make(map[Page]*registeredPageInfo, len(clones)),
+		routes: &router{
+			tree:  s.routes.tree.clone(clones),
+			files: s.routes.files,
+		},
+	}
+	for info, c := range clones {
+		c.resolvedParent = clones[info.resolvedParent]
+		c.children = make(map[string]*registeredPageInfo, len(info.children))
+		for id, child := range info.children {
+			c.children[id] = clones[child]
+		}
+		tree.registered[c.id] = c
+		tree.pages[c.page] = c
+	}
+	s.tree.Store(tree)
+}
+
+// rebuild recreates the routes after the hierarchy has changed and publishes them. Any problems will already have been reported
+// when the site was compiled. s.mux must be held.
+func (s *Site) rebuild() {
+	s.routes, _ = s.buildRoutes()
+	s.publish()
+}
+
+// placePage adds a page that was registered after the site was compiled into the hierarchy. If it cannot be placed, it is removed
+// again: a page is either served or not registered at all. s.mux must be held.
+func (s *Site) placePage(info *registeredPageInfo) error {
+	var problems []CompileProblem
+	for _, p := range s.compile() {
+		if p.PageId == info.id {
+			problems = append(problems, p)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	s.removePage(info)
+	s.rebuild()
+	return &CompileError{Problems: problems}
+}
+
+// RemovePage removes a page, and every page beneath it, from the default site
+func RemovePage(page Page) error {
+	return globalregister.RemovePage(page)
+}
+
+// RemovePage removes a page, and every page beneath it, from the site. This is safe to do while the site is serving requests.
+// Requests that have already started will finish with the pages they started with.
+func (s *Site) RemovePage(page Page) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	info := s.pageRegister[page]
+	if info == nil {
+		return fmt.Errorf("cannot remove page: page %s has not been registered", page.Name())
+	}
+	if info == s.root {
+		return errors.New("cannot remove page: the root page cannot be removed")
+	}
+	s.removePage(info)
+	if s.compiled {
+		s.rebuild()
+	}
+	logger.WithField("Id", info.id).Info("Page removed")
+	return nil
+}
+
+func (s *Site) removePage(info *registeredPageInfo) {
+	for _, c := range info.children {
+		s.removePage(c)
+	}
+	if s.registered[info.id] == info {
+		delete(s.registered, info.id)
+	}
+	if s.queued[info.id] == info {
+		delete(s.queued, info.id)
+	}
+	delete(s.pageRegister, info.page)
+	if info.resolvedParent != nil {
+		delete(info.resolvedParent.children, info.id)
+		info.resolvedParent = nil
+	}
+}
@@ -0,0 +1,93 @@
+package register
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_DynamicPages(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	consumers := &testPage{name: "consumers"}
+	site.RegisterPage(RootPageId, "consumers", consumers)
+	h := site.Handler()
+
+	navIds := func() (ret []string) {
+		ctx := newPageContext(site, site.ctx, httptest.NewRequest(http.MethodGet, "/", nil))
+		for _, c := range ctx.SiteRoot().Children() {
+			for _, cc := range c.Children() {
+				ret = append(ret, cc.Title())
+			}
+		}
+		return
+	}
+
+	orders := &testPage{name: "orders"}
+	assert.NoError(t, site.RegisterPage(consumers, "orders", orders))
+	assert.NoError(t, site.RegisterPage(orders, "lag", &testPage{name: "lag"}))
+	_, body := getBody(t, h, "/consumers/orders/lag")
+	assert.Contains(t, body, "page:lag")
+	assert.Equal(t, []string{"orders"}, navIds())
+
+	// pages that cannot be placed are not registered
+	err := site.RegisterPage("missing", "lost", &testPage{name: "lost"})
+	var ce *CompileError
+	assert.True(t, errors.As(err, &ce))
+	assert.Len(t, ce.Of(ProblemUnknownParent), 1)
+	assert.NoError(t, site.RegisterPage(RootPageId, "lost", &testPage{name: "lost"}))
+
+	// a page added later cannot take the path of an existing page
+	assert.NoError(t, site.RegisterPage(orders, "{partition}", &testPage{name: "partition"}))
+	assert.Error(t, site.RegisterPage(orders, "{offset}", &testPage{name: "offset"}))
+	_, body = getBody(t, h, "/consumers/orders/3")
+	assert.Contains(t, body, "page:partition")
+
+	assert.NoError(t, site.RemovePage(orders))
+	code, _ := getBody(t, h, "/consumers/orders/lag")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Empty(t, navIds())
+	assert.Error(t, site.RemovePage(orders))
+	assert.Error(t, site.RemovePage(site.root.page))
+}
+
+func Test_DynamicPagesConcurrent(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	h := site.Handler()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				p := &testPage{name: fmt.Sprintf("plugin-%d-%d", i, j)}
+				assert.NoError(t, site.RegisterPage(RootPageId, p.name, p))
+				assert.NoError(t, site.RemovePage(p))
+			}
+		}(i)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rec := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/plugin-%d-%d", i, j), nil)
+				h.ServeHTTP(rec, r)
+				// the page may or may not exist yet
+				assert.Contains(t, []int{http.StatusOK, http.StatusNotFound}, rec.Code)
+				ctx := newPageContext(site, site.ctx, r)
+				for _, c := range ctx.SiteRoot().Children() {
+					c.Url()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Empty(t, site.root.children)
+}
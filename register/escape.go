@@ -0,0 +1,67 @@
+package register
+
+import (
+	"html"
+	"html/template"
+	"strings"
+)
+
+// SafeHTML is markup that is written exactly as it is. Only use it for html you have written yourself; anything that came from a
+// user or another system must be escaped.
+type SafeHTML string
+
+// unsafeURL replaces urls that could run script. It is the same value html/template uses.
+const unsafeURL = "#ZgotmplZ"
+
+// urlAttributes are the attributes that hold urls, which need to be checked as well as escaped
+var urlAttributes = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "poster": true, "cite": true, "background": true,
+}
+
+// EscapeText escapes a value to be written as the text of an element. SafeHTML (and template.HTML) is written as it is.
+func EscapeText(v interface{}) string {
+	switch t := v.(type) {
+	case SafeHTML:
+		return string(t)
+	case template.HTML:
+		return string(t)
+	}
+	return html.EscapeString(getInterfaceString(v))
+}
+
+// EscapeAttribute escapes a value to be written inside a quoted attribute
+func EscapeAttribute(v interface{}) string {
+	return html.EscapeString(getInterfaceString(v))
+}
+
+// EscapeURL escapes a url to be written inside a quoted attribute. Urls with a scheme that can run script (i.e: javascript:) are
+// replaced so they do nothing.
+func EscapeURL(v interface{}) string {
+	u := getInterfaceString(v)
+	if !isSafeURL(u) {
+		return unsafeURL
+	}
+	return html.EscapeString(u)
+}
+
+func isSafeURL(u string) bool {
+	u = strings.TrimSpace(u)
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 || strings.ContainsAny(u[:colon], "/?#") {
+		// no scheme, so it is relative to the page
+		return true
+	}
+	switch strings.ToLower(u[:colon]) {
+	case "http", "https", "mailto", "tel":
+		return true
+	}
+	return false
+}
+
+// escapeAttributeValue escapes the value for the attribute it belongs to
+func escapeAttributeValue(name string, v interface{}) string {
+	if urlAttributes[strings.ToLower(name)] {
+		return EscapeURL(v)
+	}
+	return EscapeAttribute(v)
+}
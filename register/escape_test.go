@@ -0,0 +1,27 @@
+package register
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MapToAttributes(t *testing.T) {
+	assert.Equal(t, ` href="/jobs?a=1&amp;b=2" class="a b" disabled title="&#34;&gt;&lt;script&gt;"`, MapToAttributes(map[string]interface{}{
+		"title":    `"><script>`,
+		"class":    "a b",
+		"href":     "/jobs?a=1&b=2",
+		"disabled": true,
+		"hidden":   false,
+		"data-x":   nil,
+	}))
+	assert.Equal(t, ` href="#ZgotmplZ"`, MapToAttributes(map[string]interface{}{"href": " JavaScript:alert(1)"}))
+	assert.Equal(t, ` src="https://example.com/a.js"`, MapToAttributes(map[string]interface{}{"src": "https://example.com/a.js"}))
+	assert.Equal(t, "", MapToAttributes(nil))
+}
+
+func Test_EscapeText(t *testing.T) {
+	assert.Equal(t, "&lt;b&gt;", EscapeText("<b>"))
+	assert.Equal(t, "<b>", EscapeText(SafeHTML("<b>")))
+	assert.Equal(t, "42", EscapeText(42))
+}
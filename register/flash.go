@@ -0,0 +1,56 @@
+package register
+
+import (
+	"encoding/json"
+)
+
+const flashSessionKey = "GOOEY_flash"
+
+// FlashLevel says how a flash message should be shown
+type FlashLevel string
+
+const (
+	FlashInfo    = FlashLevel("info")
+	FlashSuccess = FlashLevel("success")
+	FlashWarning = FlashLevel("warning")
+	FlashError   = FlashLevel("error")
+)
+
+// Flash is a message for the user that is shown once, on the next page they see (i.e: "Worker restarted" after a form is submitted)
+type Flash struct {
+	Level   FlashLevel `json:"level"`
+	Message string     `json:"message"`
+}
+
+// AddFlash keeps a message in the session until the next time the flashes are taken to be shown
+func AddFlash(ctx PageContext, level FlashLevel, message string) {
+	flashes := readFlashes(ctx.Session())
+	flashes = append(flashes, Flash{Level: level, Message: message})
+	b, err := json.Marshal(flashes)
+	if err != nil {
+		logger.WithError(err).Error("failed to store flash message")
+		return
+	}
+	ctx.Session().Set(flashSessionKey, string(b))
+}
+
+// TakeFlashes returns the flash messages waiting to be shown and removes them from the session, so they are only shown once
+func TakeFlashes(ctx PageContext) []Flash {
+	sess := ctx.Session()
+	flashes := readFlashes(sess)
+	sess.Remove(flashSessionKey)
+	return flashes
+}
+
+func readFlashes(sess *Session) []Flash {
+	v, ok := sess.Get(flashSessionKey)
+	if !ok {
+		return nil
+	}
+	var flashes []Flash
+	if err := json.Unmarshal([]byte(v), &flashes); err != nil {
+		logger.WithError(err).Warn("discarding unreadable flash messages")
+		return nil
+	}
+	return flashes
+}
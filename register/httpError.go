@@ -0,0 +1,125 @@
+package register
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// HTTPError is an error that should be reported with a particular status code
+type HTTPError struct {
+	StatusCode int
+	Err        error
+}
+
+// NewHTTPError creates an error that will be reported with the status code. If err is nil, the text of the status code is used.
+func NewHTTPError(statusCode int, err error) *HTTPError {
+	return &HTTPError{StatusCode: statusCode, Err: err}
+}
+
+// HTTPErrorf creates an error with a formatted message that will be reported with the status code
+func HTTPErrorf(statusCode int, format string, a ...interface{}) *HTTPError {
+	return &HTTPError{StatusCode: statusCode, Err: fmt.Errorf(format, a...)}
+}
+
+func (he *HTTPError) Error() string {
+	if he.Err == nil {
+		return http.StatusText(he.StatusCode)
+	}
+	return he.Err.Error()
+}
+
+func (he *HTTPError) Unwrap() error {
+	return he.Err
+}
+
+// StatusCodeOf returns the status code an error should be reported with. Errors that do not have one are a 500.
+func StatusCodeOf(err error) int {
+	var he *HTTPError
+	if errors.As(err, &he) && he.StatusCode != 0 {
+		return he.StatusCode
+	}
+	var be *BindError
+	if errors.As(err, &be) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// PanicError is the error a page or component is treated as having returned when it panics
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Recovered creates a PanicError from the value returned by recover(). It must be called from the deferred function that recovered.
+func Recovered(v interface{}) *PanicError {
+	return &PanicError{Value: v, Stack: debug.Stack()}
+}
+
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
+}
+
+// SetDevMode controls whether the default site shows the details of errors
+func SetDevMode(on bool) {
+	globalregister.SetDevMode(on)
+}
+
+// SetDevMode controls whether the details of errors (messages of internal errors and the stack of panics) are shown to the user.
+// This should never be turned on where the site can be reached by people who should not see the internals of the application.
+func (s *Site) SetDevMode(on bool) {
+	s.devMode = on
+}
+
+// DevMode returns true if the details of errors are shown to the user
+func (s *Site) DevMode() bool {
+	return s.devMode
+}
+
+// ErrorDetails describes the error the error page is rendering
+type ErrorDetails struct {
+	StatusCode int
+	// A message that can be shown to anyone
+	Message string
+	// The error itself, and the stack if a page panicked. Only set in dev mode
+	Detail string
+}
+
+// ErrorMessage returns the message that can be shown to the user for the error. The messages of internal errors are only shown in dev mode,
+// while errors a page has given a status below 500 to (i.e: a 404) are considered safe to show.
+func ErrorMessage(ctx PageContext, err error) string {
+	code := StatusCodeOf(err)
+	if code < http.StatusInternalServerError || devModeOf(ctx) {
+		return err.Error()
+	}
+	return "An unexpected error occurred"
+}
+
+// GetErrorDetails returns the details of the error being rendered by the error page. Nil if there is no error
+func GetErrorDetails(ctx PageContext) *ErrorDetails {
+	err := GetPageError(ctx)
+	if err == nil {
+		return nil
+	}
+	details := &ErrorDetails{
+		StatusCode: StatusCodeOf(err),
+		Message:    ErrorMessage(ctx, err),
+	}
+	if devModeOf(ctx) {
+		details.Detail = err.Error()
+		var pe *PanicError
+		if errors.As(err, &pe) {
+			details.Detail += "\n\n" + string(pe.Stack)
+		}
+	}
+	return details
+}
+
+func devModeOf(ctx PageContext) bool {
+	if pc, ok := ctx.(*pageContext); ok {
+		return pc.site.devMode
+	}
+	return globalregister.devMode
+}
@@ -0,0 +1,64 @@
+package register
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type funcPage struct {
+	name string
+	f    func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{}
+}
+
+func (fp *funcPage) Name() string {
+	return fp.name
+}
+
+func (fp *funcPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	return fp.f(ctx, w, r)
+}
+
+func Test_ErrorPages(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "secret", &funcPage{name: "secret", f: func(PageContext, http.ResponseWriter, *http.Request) interface{} {
+		return HTTPErrorf(http.StatusForbidden, "no peeking")
+	}})
+	site.RegisterPage(RootPageId, "panic", &funcPage{name: "panic", f: func(PageContext, http.ResponseWriter, *http.Request) interface{} {
+		panic("kaboom")
+	}})
+	h := site.Handler()
+
+	// the document has already been started by the time the page runs, so only the body can tell us about the error
+	_, body := getBody(t, h, "/secret")
+	assert.Contains(t, body, "no peeking")
+
+	_, body = getBody(t, h, "/panic")
+	assert.Contains(t, body, "An unexpected error occurred")
+	assert.NotContains(t, body, "kaboom")
+
+	site.SetDevMode(true)
+	_, body = getBody(t, h, "/panic")
+	assert.Contains(t, body, "panic: kaboom")
+	assert.Contains(t, body, "GOOEY_errordetail")
+	site.SetDevMode(false)
+
+	code, body := getBody(t, h, "/nowhere")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body, "/nowhere could not be found")
+
+	site.CorePages().NotFoundPage = &testPage{name: "lost"}
+	code, body = getBody(t, h, "/nowhere")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, body, "page:lost")
+
+	// buffering lets the error page set the status, even though the document had been started
+	site.SetRenderMode(RenderBuffered)
+	code, body = getBody(t, h, "/secret")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Contains(t, body, "no peeking")
+	code, _ = getBody(t, h, "/panic")
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
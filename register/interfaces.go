@@ -2,6 +2,7 @@ package register
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -29,11 +30,20 @@ type PageBehaviour interface {
 	QueryBehaviour(ctx PageContext, b Behaviour) Behaviour
 }
 
+// PageStructure describes a page as it should appear in menus
 type PageStructure interface {
 	Page() Page
 	Title() string
+	// Pages with a lower weight are shown first. Pages with the same weight are ordered by title
 	Weight() int
 	Children() []PageStructure
+	// Icon to show with the page. How it is interpreted (i.e: a css class or image url) is up to the layout
+	Icon() string
+	// Description is a short explanation of the page (i.e: a tooltip)
+	Description() string
+	// NavGroup is the name of the group the page is shown in. Empty if it is not grouped
+	NavGroup() string
+	Url() *url.URL
 }
 
 type PageStructureCollection []PageStructure
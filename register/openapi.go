@@ -0,0 +1,452 @@
+package register
+
+import (
+	"encoding"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIDocument is an OpenAPI 3 description of the api pages of a site
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	Url string `json:"url"`
+}
+
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationId string                      `json:"operationId,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+}
+
+// OpenAPISchema is the subset of JSON schema used to describe the types of api pages
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+const openAPIVersion = "3.0.3"
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// the methods that do not have a body, so their requests are given in the query string
+var queryMethods = map[string]bool{http.MethodGet: true, http.MethodHead: true, http.MethodDelete: true}
+
+// OpenAPI describes the api pages of the default site that the request is allowed to see
+func OpenAPI(ctx PageContext) *OpenAPIDocument {
+	return globalregister.OpenAPI(ctx)
+}
+
+// OpenAPI describes the api pages of the site that the request is allowed to see. Private pages are never described.
+func (s *Site) OpenAPI(ctx PageContext) *OpenAPIDocument {
+	doc := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   "API",
+			Version: "1.0",
+		},
+		Paths: map[string]map[string]*OpenAPIOperation{},
+	}
+	if pc, ok := ctx.(*pageContext); ok {
+		// the api is wherever the client reached the site, which may be behind a proxy
+		doc.Servers = []OpenAPIServer{{Url: pc.buildUrlFromRoot("")}}
+	} else if s.prefix != "" {
+		doc.Servers = []OpenAPIServer{{Url: s.prefix}}
+	}
+	if title := s.treeFor(ctx).root.nav.title; title != "" {
+		doc.Info.Title = title
+	}
+	gen := &schemaGenerator{schemas: map[string]*OpenAPISchema{}, seen: map[reflect.Type]string{}}
+	for _, info := range s.apiPages(ctx) {
+		ap := info.page.(*APIPage)
+		path, params := openAPIPath(info.path)
+		op := &OpenAPIOperation{
+			Summary:     info.nav.title,
+			Description: info.nav.description,
+			OperationId: info.id,
+			Parameters:  params,
+			Responses:   map[string]*OpenAPIResponse{},
+		}
+		if op.Summary == "" {
+			op.Summary = ap.Name()
+		}
+		method := http.MethodGet
+		if ap.api == nil {
+			// the page handles the request itself, so all we know is where it is
+			op.Responses["default"] = &OpenAPIResponse{Description: "Response"}
+		} else {
+			method = ap.api.method
+			if queryMethods[method] {
+				op.Parameters = append(op.Parameters, gen.queryParameters("", ap.api.request, map[reflect.Type]bool{})...)
+			} else {
+				op.RequestBody = &OpenAPIRequestBody{
+					Required: true,
+					Content:  jsonContent(gen.schemaOf(ap.api.request)),
+				}
+			}
+			op.Responses[strconv.Itoa(ap.api.successStatus)] = &OpenAPIResponse{
+				Description: http.StatusText(ap.api.successStatus),
+				Content:     jsonContent(gen.schemaOf(ap.api.response)),
+			}
+			op.Responses["default"] = &OpenAPIResponse{
+				Description: "Error",
+				Content:     jsonContent(gen.schemaOf(reflect.TypeOf(jsonError{}))),
+			}
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*OpenAPIOperation{}
+		}
+		doc.Paths[path][strings.ToLower(method)] = op
+	}
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// apiPages returns the api pages the request can see, ordered by path
+func (s *Site) apiPages(ctx PageContext) []*registeredPageInfo {
+	var ret []*registeredPageInfo
+	for _, info := range s.treeFor(ctx).registered {
+		if _, ok := info.page.(*APIPage); !ok || info.private || info.path == "" {
+			continue
+		}
+		if !s.authorize(info.page, ctx).Authorized() {
+			continue
+		}
+		ret = append(ret, info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].path < ret[j].path
+	})
+	return ret
+}
+
+// openAPIPath turns the path of a page into an OpenAPI path and its parameters. Wildcards become plain parameters.
+func openAPIPath(path string) (string, []*OpenAPIParameter) {
+	var params []*OpenAPIParameter
+	segs := strings.Split(path, "/")
+	for ix, seg := range segs {
+		if name, kind := parseSegment(seg); kind != staticSegment {
+			segs[ix] = "{" + name + "}"
+			params = append(params, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+func jsonContent(schema *OpenAPISchema) map[string]*OpenAPIMediaType {
+	return map[string]*OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+	// the name each named struct has been given in the schemas
+	seen map[reflect.Type]string
+}
+
+var schemaNameCleaner = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// schemaOf describes how the type is written as JSON. Named structs are added to the components and referenced.
+func (g *schemaGenerator) schemaOf(t reflect.Type) *OpenAPISchema {
+	if t.Kind() == reflect.Ptr {
+		s := g.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		s.Nullable = true
+		return s
+	}
+	switch {
+	case t == timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		return &OpenAPISchema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &OpenAPISchema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// only a []byte is marshalled as base64. A [N]byte array is an array of numbers like any other
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name, ok := g.seen[t]
+		if !ok {
+			name = g.schemaName(t)
+			g.seen[t] = name
+			// registered before it is built, so types that refer to themselves terminate
+			g.schemas[name] = &OpenAPISchema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+	}
+	// interfaces can be anything
+	return &OpenAPISchema{}
+}
+
+func (g *schemaGenerator) schemaName(t reflect.Type) string {
+	base := schemaNameCleaner.ReplaceAllString(t.Name(), "_")
+	name := base
+	for ix := 2; g.schemas[name] != nil; ix++ {
+		name = base + strconv.Itoa(ix)
+	}
+	return name
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: map[string]*OpenAPISchema{}}
+	for ix := 0; ix < t.NumField(); ix++ {
+		f := t.Field(ix)
+		name, ok := jsonFieldName(f)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			// embedded structs are written as part of the struct they are embedded in
+			for k, v := range g.structSchema(indirectType(f.Type)).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		s.Properties[name] = g.schemaOf(f.Type)
+	}
+	return s
+}
+
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := strings.Split(f.Tag.Get("json"), ",")[0]
+	switch {
+	case tag == "-":
+		return "", false
+	case tag != "":
+		return tag, true
+	}
+	return f.Name, true
+}
+
+// queryParameters describes the fields of a request that are bound from the query string (see Bind). Types that refer to themselves
+// are only described down to where they repeat.
+func (g *schemaGenerator) queryParameters(prefix string, t reflect.Type, visiting map[reflect.Type]bool) []*OpenAPIParameter {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct || isScalar(t) || visiting[t] {
+		return nil
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	var params []*OpenAPIParameter
+	for ix := 0; ix < t.NumField(); ix++ {
+		f := t.Field(ix)
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("form") == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			params = append(params, g.queryParameters(prefix, f.Type, visiting)...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if ft := indirectType(f.Type); !isScalar(ft) {
+			params = append(params, g.queryParameters(name, ft, visiting)...)
+			continue
+		}
+		params = append(params, &OpenAPIParameter{Name: name, In: "query", Schema: g.schemaOf(f.Type)})
+	}
+	return params
+}
+
+// RegisterAPIReference adds a page to the default site that lists its api pages, along with the OpenAPI document that describes them
+func RegisterAPIReference(parent interface{}, id string, opts ...PageOption) error {
+	return globalregister.RegisterAPIReference(parent, id, opts...)
+}
+
+// RegisterAPIReference adds a page to the site that lists its api pages. The OpenAPI document that describes them is served beneath
+// it (i.e: /api/openapi.json).
+func (s *Site) RegisterAPIReference(parent interface{}, id string, opts ...PageOption) error {
+	return s.RegisterPage(parent, id, &apiReferencePage{site: s}, opts...)
+}
+
+type apiReferencePage struct {
+	site    *Site
+	docPage Page
+}
+
+func (arp *apiReferencePage) Name() string {
+	return "API Reference"
+}
+
+func (arp *apiReferencePage) OnHandlerAdded(rc Registerer) {
+	arp.docPage = NewAPIPage("OpenAPI", func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		writeJSON(w, http.StatusOK, arp.site.OpenAPI(ctx))
+		return nil
+	})
+	rc.RegisterPrivateSubPage("openapi.json", arp.docPage)
+}
+
+type apiReferenceOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Parameters  []*OpenAPIParameter
+	Request     string
+	Response    string
+}
+
+var apiReferenceTemplate = template.Must(template.New("apireference").Parse(`<div class="GOOEY GOOEY_apireference">
+<p><a href="{{.DocUrl}}">OpenAPI document</a></p>
+{{range .Operations}}<div class="GOOEY_apioperation">
+<h3><code>{{.Method}} {{.Path}}</code> {{.Summary}}</h3>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Parameters}}<table><tr><th>Parameter</th><th>In</th><th>Type</th></tr>
+{{range .Parameters}}<tr><td>{{.Name}}</td><td>{{.In}}</td><td>{{.Schema.Type}}</td></tr>
+{{end}}</table>{{end}}
+{{if .Request}}<h4>Request</h4><pre>{{.Request}}</pre>{{end}}
+{{if .Response}}<h4>Response</h4><pre>{{.Response}}</pre>{{end}}
+</div>
+{{else}}<p>There are no api pages</p>{{end}}
+</div>`))
+
+func (arp *apiReferencePage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	doc := arp.site.OpenAPI(ctx)
+	var ops []apiReferenceOperation
+	for path, methods := range doc.Paths {
+		for method, op := range methods {
+			ref := apiReferenceOperation{
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				Summary:     op.Summary,
+				Description: op.Description,
+				Parameters:  op.Parameters,
+			}
+			if op.RequestBody != nil {
+				ref.Request = describeSchema(doc, op.RequestBody.Content["application/json"].Schema)
+			}
+			for code, resp := range op.Responses {
+				if code != "default" && resp.Content != nil {
+					ref.Response = describeSchema(doc, resp.Content["application/json"].Schema)
+				}
+			}
+			ops = append(ops, ref)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path == ops[j].Path {
+			return ops[i].Method < ops[j].Method
+		}
+		return ops[i].Path < ops[j].Path
+	})
+	apiReferenceTemplate.Execute(w, map[string]interface{}{
+		"DocUrl":     ctx.GetPageUrl(arp.docPage).String(),
+		"Operations": ops,
+	})
+	return nil
+}
+
+// describeSchema writes the schema as JSON, with any references to named types filled in
+func describeSchema(doc *OpenAPIDocument, s *OpenAPISchema) string {
+	data, _ := json.MarshalIndent(resolveSchema(doc, s, map[string]bool{}), "", "  ")
+	return string(data)
+}
+
+func resolveSchema(doc *OpenAPIDocument, s *OpenAPISchema, resolving map[string]bool) *OpenAPISchema {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if resolving[name] {
+			// the type refers to itself
+			return s
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+		return resolveSchema(doc, doc.Components.Schemas[name], resolving)
+	}
+	c := *s
+	c.Items = resolveSchema(doc, s.Items, resolving)
+	c.AdditionalProperties = resolveSchema(doc, s.AdditionalProperties, resolving)
+	if s.Properties != nil {
+		c.Properties = map[string]*OpenAPISchema{}
+		for k, v := range s.Properties {
+			c.Properties[k] = resolveSchema(doc, v, resolving)
+		}
+	}
+	return &c
+}
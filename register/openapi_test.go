@@ -0,0 +1,194 @@
+package register
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type apiWorker struct {
+	Name    string     `json:"name"`
+	Started time.Time  `json:"started"`
+	Manager *apiWorker `json:"manager,omitempty"`
+}
+
+// bearerAuthenticator signs in the request if it carries the token
+type bearerAuthenticator string
+
+func (ba bearerAuthenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*User, error) {
+	if r.Header.Get("Authorization") == "Bearer "+string(ba) {
+		return &User{Id: "script"}, nil
+	}
+	return nil, nil
+}
+
+func Test_TypedAPIPage(t *testing.T) {
+	type listRequest struct {
+		Limit  int
+		Filter struct {
+			Name string
+		}
+	}
+	type createRequest struct {
+		Name string `json:"name"`
+	}
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "api", &testPage{name: "api"})
+	site.RegisterPage("api", "workers", NewTypedAPIPage("Workers", func(ctx PageContext, req listRequest) ([]apiWorker, error) {
+		if req.Filter.Name == "missing" {
+			return nil, HTTPErrorf(http.StatusNotFound, "no worker called %s", req.Filter.Name)
+		}
+		return make([]apiWorker, req.Limit), nil
+	}), WithDescription("Lists the workers"), CSRFExempt())
+	site.RegisterPage("workers", "{workerId}", NewTypedAPIPage("Create worker", func(ctx PageContext, req createRequest) (*apiWorker, error) {
+		if req.Name == "" {
+			return nil, HTTPErrorf(http.StatusUnprocessableEntity, "a name is required")
+		}
+		return &apiWorker{Name: ctx.PathParam("workerId") + ":" + req.Name}, nil
+	}, WithMethod(http.MethodPost), WithSuccessStatus(http.StatusCreated)), CSRFExempt())
+	site.RegisterPage("api", "raw", NewAPIPage("Raw", func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		return nil
+	}))
+	assert.NoError(t, site.RegisterAPIReference(RootPageId, "reference"))
+	site.AddAuthenticator(bearerAuthenticator("s3cret"))
+	h := site.Handler()
+
+	// the request comes from a browser that has a session, unless it is given a bearer token
+	cookie := &http.Cookie{Name: defaultSessionCookie, Value: site.sessions.sign("browser")}
+	request := func(method, target, body string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		if len(header) == 2 {
+			r.Header.Set(header[0], header[1])
+		} else {
+			r.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := request(http.MethodGet, "/api/workers?limit=2", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var workers []apiWorker
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &workers))
+	assert.Len(t, workers, 2)
+
+	rec = request(http.MethodGet, "/api/workers?limit=lots", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"fields":{"Limit"`)
+
+	rec = request(http.MethodGet, "/api/workers?filter.name=missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"error":"no worker called missing"}`, rec.Body.String())
+
+	rec = request(http.MethodPost, "/api/workers", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, http.MethodGet, rec.Header().Get("Allow"))
+
+	rec = request(http.MethodPost, "/api/workers/w1", `{"name":"new"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"w1:new"`)
+
+	rec = request(http.MethodPost, "/api/workers/w1", `{"name":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = request(http.MethodPost, "/api/workers/w1", `{"name":""}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "a name is required")
+
+	// api pages are not exempt from the CSRF check unless they ask to be
+	rec = request(http.MethodPost, "/api/raw", `{}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	// a browser can't be made to send a bearer token, so the call can't have been forged
+	rec = request(http.MethodPost, "/api/raw", `{}`, "Authorization", "Bearer s3cret")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = request(http.MethodGet, "/reference/openapi.json", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var doc OpenAPIDocument
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, []OpenAPIServer{{Url: "http://example.com"}}, doc.Servers)
+	assert.Len(t, doc.Paths, 3)
+
+	list := doc.Paths["/api/workers"]["get"]
+	if assert.NotNil(t, list) {
+		assert.Equal(t, "Workers", list.Summary)
+		assert.Equal(t, "Lists the workers", list.Description)
+		var names []string
+		for _, p := range list.Parameters {
+			names = append(names, p.In+":"+p.Name)
+		}
+		assert.Equal(t, []string{"query:Limit", "query:Filter.Name"}, names)
+		assert.Equal(t, "array", list.Responses["200"].Content["application/json"].Schema.Type)
+		assert.Equal(t, "#/components/schemas/apiWorker", list.Responses["200"].Content["application/json"].Schema.Items.Ref)
+	}
+	create := doc.Paths["/api/workers/{workerId}"]["post"]
+	if assert.NotNil(t, create) {
+		assert.Equal(t, "path", create.Parameters[0].In)
+		assert.Equal(t, "#/components/schemas/createRequest", create.RequestBody.Content["application/json"].Schema.Ref)
+		assert.Equal(t, "string", doc.Components.Schemas["createRequest"].Properties["name"].Type)
+		assert.NotNil(t, create.Responses["201"])
+	}
+	assert.NotNil(t, doc.Paths["/api/raw"]["get"])
+	worker := doc.Components.Schemas["apiWorker"]
+	if assert.NotNil(t, worker) {
+		assert.Equal(t, "date-time", worker.Properties["started"].Format)
+		assert.Equal(t, "#/components/schemas/apiWorker", worker.Properties["manager"].Ref)
+	}
+
+	rec = request(http.MethodGet, "/reference", "")
+	assert.Contains(t, rec.Body.String(), "<code>POST /api/workers/{workerId}</code> Create worker")
+	assert.Contains(t, rec.Body.String(), "/reference/openapi.json")
+
+	// behind a proxy, the server is where the client reached the site
+	site.SetProxyPolicy(&ProxyPolicy{TrustAll: true})
+	r := httptest.NewRequest(http.MethodGet, "/reference/openapi.json", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Prefix", "/ops")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	doc = OpenAPIDocument{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, []OpenAPIServer{{Url: "https://example.com/ops"}}, doc.Servers)
+}
+
+type treeFilter struct {
+	Name   string
+	Parent *treeFilter
+}
+
+func Test_QueryParametersRecursive(t *testing.T) {
+	type request struct {
+		Limit  int
+		Filter treeFilter
+	}
+	gen := &schemaGenerator{schemas: map[string]*OpenAPISchema{}, seen: map[reflect.Type]string{}}
+	params := gen.queryParameters("", reflect.TypeOf(request{}), map[reflect.Type]bool{})
+	var names []string
+	for _, p := range params {
+		names = append(names, p.Name)
+	}
+	// the filter is described once rather than forever
+	assert.Equal(t, []string{"Limit", "Filter.Name"}, names)
+}
+
+func Test_ByteSchemas(t *testing.T) {
+	gen := &schemaGenerator{schemas: map[string]*OpenAPISchema{}, seen: map[reflect.Type]string{}}
+	assert.Equal(t, &OpenAPISchema{Type: "string", Format: "byte"}, gen.schemaOf(reflect.TypeOf([]byte{})))
+	// arrays are marshalled as numbers, not base64
+	sum := gen.schemaOf(reflect.TypeOf([32]byte{}))
+	assert.Equal(t, "array", sum.Type)
+	assert.Equal(t, "integer", sum.Items.Type)
+}
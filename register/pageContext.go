@@ -5,42 +5,75 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"strings"
 	"sync/atomic"
-
-	"github.com/sirupsen/logrus"
 )
 
 // PageContext provides functionality that a component or page might need to perform its functions
 type PageContext interface {
 	context.Context
-	GetPageUrl(p Page) *url.URL
+	// GetPageUrl returns the url of the page. Values for the path parameters of the page are given in order, and any that are not
+	// given are taken from the current request.
+	GetPageUrl(p Page, params ...interface{}) *url.URL
 	// Context data refers to any additional parameters or modifiers that have changed how the page has been called (i.e: query string in URL)
 	GetContextData() map[string][]string
-	UnmarshallData(interface{})
+	// UnmarshallData fills v (a pointer to a struct) from the query string and body of the request. If any values cannot be converted,
+	// a *BindError is returned listing each field.
+	UnmarshallData(v interface{}) error
 	ResolveUrl(i interface{}) (*url.URL, error)
 	SiteRoot() PageStructure
 	Resolve(i interface{}, rk ResolutionKind) string
 	// Gets the Request cache: a key-value store that will persist for the life of the request
 	RequestCache() Cache
+	// AppCache is shared by every request to the site. Use it for expensive lookups that are the same for everyone, keyed accordingly
+	AppCache() SharedCache
+	// SessionCache is shared by the requests of the current session. It is dropped when the session is destroyed
+	SessionCache() SharedCache
 	// GetNewSequence returns a new unique incrementing number that can be used to create unique elements on a page
 	GetNewSequence() uint64
+	// Session returns the session of the browser making the request
+	Session() *Session
+	// User returns the user signed in to the session. Nil if the request is anonymous
+	User() *User
+	// CurrentPage returns the page that is handling the request
+	CurrentPage() Page
+	// PathParam returns the value of a parameter in the path of the page (i.e: jobId in /jobs/{jobId}). Empty if it does not exist
+	PathParam(name string) string
+	// PathParams returns all parameters in the path of the page
+	PathParams() map[string]string
+	// Breadcrumbs returns the pages from the root of the site to the current page
+	Breadcrumbs() []PageStructure
+	// Nonce returns the nonce of the request. Any inline script or style written by hand needs it (nonce="...") to be allowed by the
+	// Content-Security-Policy
+	Nonce() string
 }
 
 var _ PageContext = (*pageContext)(nil)
 
 type pageContext struct {
 	context.Context
+	site     *Site
+	origin   requestOrigin
 	request  *http.Request
 	reqCache Cache
 	sequence uint64
+	session  *Session
+	page     Page
+	params   map[string]string
+	// the snapshot of the page hierarchy the request is being served from
+	tree *siteTree
+	// the user identified from the request itself rather than the session
+	requestUser *User
+	nonce       string
 }
 
-func newPageContext(ctx context.Context, r *http.Request) *pageContext {
+func newPageContext(site *Site, ctx context.Context, r *http.Request) *pageContext {
 	return &pageContext{
 		Context:  ctx,
+		site:     site,
+		origin:   site.resolveOrigin(r),
 		request:  r,
 		reqCache: newMemoryCache(),
+		tree:     site.currentTree(),
 	}
 }
 
@@ -48,42 +81,83 @@ func (pctx *pageContext) GetNewSequence() uint64 {
 	return atomic.AddUint64(&pctx.sequence, 1)
 }
 
+func (pctx *pageContext) Session() *Session {
+	if pctx.session == nil {
+		// sessions are attached as the request is handled. Anything outside of that gets a throwaway session
+		pctx.session = newSession()
+	}
+	return pctx.session
+}
+
+func (pctx *pageContext) User() *User {
+	if pctx.requestUser != nil {
+		return pctx.requestUser
+	}
+	return pctx.Session().User()
+}
+
+func (pctx *pageContext) CurrentPage() Page {
+	return pctx.page
+}
+
+func (pctx *pageContext) PathParam(name string) string {
+	return pctx.params[name]
+}
+
+func (pctx *pageContext) PathParams() map[string]string {
+	return pctx.params
+}
+
+func (pctx *pageContext) Breadcrumbs() []PageStructure {
+	var crumbs []PageStructure
+	for info := pctx.site.treeFor(pctx).pages[pctx.page]; info != nil; info = info.resolvedParent {
+		crumbs = append([]PageStructure{createpageStructureData(pctx.site, pctx, info.page)}, crumbs...)
+	}
+	return crumbs
+}
+
 func (pctx *pageContext) RequestCache() Cache {
 	return pctx.reqCache
 }
 
+func (pctx *pageContext) AppCache() SharedCache {
+	return pctx.site.appCache
+}
+
+func (pctx *pageContext) SessionCache() SharedCache {
+	return pctx.site.sessionCache(pctx.Session())
+}
+
 func (pctx *pageContext) SiteRoot() PageStructure {
-	return globalregister.getSiteStructure()
+	return pctx.site.getSiteStructure(pctx)
 }
 
 func (pctx *pageContext) GetContextData() map[string][]string {
 
 	return pctx.request.URL.Query()
 }
-func (pctx *pageContext) UnmarshallData(v interface{}) {
-	err := pctx.request.ParseForm()
-	if err != nil {
-		logrus.Error(err)
-		return
-	}
-	// valMap := make(map[string]interface{})
-	// for k, v := range pctx.request.Form() {
-
-	// }
-	// first, we need to simplify our structure as required.
+func (pctx *pageContext) UnmarshallData(v interface{}) error {
+	return BindRequest(pctx.request, v)
+}
 
+// requestUrl is the url as the client requested it (before any path prefix was stripped)
+func (pctx *pageContext) requestUrl() *url.URL {
+	if u, ok := pctx.request.Context().Value(originalUrlKey).(*url.URL); ok {
+		return u
+	}
+	return pctx.request.URL
 }
 
 func (pctx *pageContext) buildUrlFromRoot(path string) string {
-	return fmt.Sprintf("%s://%s", getSchemeFromProto(pctx.request.Proto), pctx.request.Host) + path
+	return fmt.Sprintf("%s://%s", pctx.origin.scheme, pctx.origin.host) + pctx.origin.prefix + pctx.site.prefix + path
 }
 
-func (pctx *pageContext) GetPageUrl(p Page) *url.URL {
-	info := globalregister.FindPage(p)
+func (pctx *pageContext) GetPageUrl(p Page, params ...interface{}) *url.URL {
+	info := pctx.site.treeFor(pctx).pages[p]
 	if info == nil {
 		panic("failed to resolve required page")
 	}
-	basePath := pctx.buildUrlFromRoot(info.path)
+	basePath := pctx.buildUrlFromRoot(expandPath(info.path, pctx.params, params))
 
 	u, err := url.Parse(basePath)
 	if err != nil {
@@ -92,15 +166,6 @@ func (pctx *pageContext) GetPageUrl(p Page) *url.URL {
 	return u
 }
 
-func getSchemeFromProto(proto string) string {
-	parts := strings.Split(proto, "/")
-	switch {
-	case strings.EqualFold(parts[0], "http"):
-		return "http"
-	}
-	return "http"
-}
-
 func (pctx *pageContext) Resolve(i interface{}, rk ResolutionKind) string {
 	switch it := i.(type) {
 	case Resolvable:
@@ -131,9 +196,9 @@ func (pctx *pageContext) ResolveUrl(i interface{}) (*url.URL, error) {
 		return url.Parse(pctx.buildUrlFromRoot(v.FullPath()))
 	case string:
 		// in this case, we assume the path is relative
-		return pctx.request.URL.Parse(v)
+		return pctx.requestUrl().Parse(v)
 	case *url.URL:
-		return pctx.request.URL.ResolveReference(v), nil
+		return pctx.requestUrl().ResolveReference(v), nil
 	default:
 		return nil, fmt.Errorf("cannot use %t to resolve a url", i)
 	}
@@ -2,6 +2,7 @@ package register
 
 import (
 	"fmt"
+	"html"
 	"io"
 	"strings"
 )
@@ -85,12 +86,23 @@ func RenderPageElement(ctx PageContext, e RenderableElement) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	for k, v := range attr {
+		// files and pages need to be resolved against the site so that any path prefix is honoured
+		switch v.(type) {
+		case GOOEYFile, Page:
+			u, err := ctx.ResolveUrl(v)
+			if err != nil {
+				return "", err
+			}
+			attr[k] = u
+		}
+	}
 	switch e.GetKind() {
 	case ElementTag_Closing:
 		return fmt.Sprintf(`<%s%s>%s</%s>`,
 			e.GetElementName(ctx),
 			MapToAttributes(attr),
-			e.GetInnerText(ctx),
+			EscapeText(e.GetInnerText(ctx)),
 			e.GetElementName(ctx)), nil
 	case ElementTag_NoClose:
 		return fmt.Sprintf(`<%s%s>`, e.GetElementName(ctx), MapToAttributes(attr)), nil
@@ -110,8 +122,18 @@ func (pe *PageElement) AddAttribute(key string, val interface{}) *PageElement {
 	return pe
 }
 
+// PageHead is everything that goes in the <head> of the document. Layouts and pages add to it through Behaviour.WithMetaHandling, and
+// anything added more than once (the same meta name, link or script) is only written once.
 type PageHead struct {
-	Links []*LinkElement
+	// Title of the document. If not set, the name of the page followed by the name of the site is used
+	Title string
+	// Canonical is the url (or page/file) that is the canonical address of the page
+	Canonical interface{}
+	Metas     []*PageElement
+	Links     []*LinkElement
+	Scripts   []*ScriptElement
+	// Styles are written inline as <style> elements
+	Styles []string
 }
 
 type LinkElement struct {
@@ -138,23 +160,107 @@ type LinkKind string
 
 const (
 	Stylesheet = LinkKind("stylesheet")
+	Icon       = LinkKind("icon")
+	canonical  = LinkKind("canonical")
 )
 
+// ScriptElement is a script loaded by the page
+type ScriptElement struct {
+	*PageElement
+	Src interface{}
+}
+
+func (se *ScriptElement) GetAttributes(ctx PageContext) (map[string]interface{}, error) {
+	m, err := se.PageElement.GetAttributes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	url, err := ctx.ResolveUrl(se.Src)
+	if err != nil {
+		return nil, err
+	}
+	m["src"] = url
+	m["nonce"] = ctx.Nonce()
+	return m, nil
+}
+
+// Defer runs the script once the document has been parsed
+func (se *ScriptElement) Defer() *ScriptElement {
+	se.SetAttribute("defer", "defer")
+	return se
+}
+
+// Async runs the script as soon as it is available
+func (se *ScriptElement) Async() *ScriptElement {
+	se.SetAttribute("async", "async")
+	return se
+}
+
+// Module loads the script as an ES module
+func (se *ScriptElement) Module() *ScriptElement {
+	se.SetAttribute("type", "module")
+	return se
+}
+
+// SetTitle sets the title of the document
+func (pm *PageHead) SetTitle(title string) *PageHead {
+	pm.Title = title
+	return pm
+}
+
+// SetCanonical sets the canonical address of the page. The target can be anything that can be resolved to a url (a url, page or file)
+func (pm *PageHead) SetCanonical(target interface{}) *PageHead {
+	pm.Canonical = target
+	return pm
+}
+
+// metaKey identifies meta elements that replace each other. Metas without a charset, name, property or http-equiv are never replaced.
+func metaKey(vals map[string]interface{}) string {
+	for _, k := range []string{"charset", "name", "property", "http-equiv"} {
+		if v, ok := vals[k]; ok {
+			if k == "charset" {
+				return k
+			}
+			return k + ":" + strings.ToLower(getInterfaceString(v))
+		}
+	}
+	return ""
+}
+
+// AddMeta adds a meta element. A meta with the same charset, name, property or http-equiv as an existing one replaces it, so a page
+// can override what the layout has set.
 func (pm *PageHead) AddMeta(vals map[string]interface{}) *PageElement {
 	pe := &PageElement{
 		AttibutingElement: &AttibutingElement{
 			Attributes: vals,
 		},
 		ElementName: "meta",
+		Kind:        ElementTag_NoClose,
 	}
+	if key := metaKey(vals); key != "" {
+		for i, m := range pm.Metas {
+			if metaKey(m.Attributes) == key {
+				pm.Metas[i] = pe
+				return pe
+			}
+		}
+	}
+	pm.Metas = append(pm.Metas, pe)
 	return pe
 }
 
+// AddLink adds a link to the head. If the same link has already been added, that one is returned instead.
 func (pm *PageHead) AddLink(kind LinkKind, source interface{}) *LinkElement {
+	for _, l := range pm.Links {
+		if l.LinkType == string(kind) && sameSource(l.Href, source) {
+			return l
+		}
+	}
 	l := &LinkElement{
 		PageElement: &PageElement{
-			Kind:        ElementTag_NoClose,
-			ElementName: "link",
+			AttibutingElement: &AttibutingElement{},
+			Kind:              ElementTag_NoClose,
+			ElementName:       "link",
 		},
 		LinkType: string(kind),
 		Href:     source,
@@ -163,19 +269,94 @@ func (pm *PageHead) AddLink(kind LinkKind, source interface{}) *LinkElement {
 	return l
 }
 
+// AddFavicon adds an icon for the page. Sizes (i.e: "32x32") can be left empty.
+func (pm *PageHead) AddFavicon(source interface{}, sizes string) *LinkElement {
+	l := pm.AddLink(Icon, source)
+	if sizes != "" {
+		l.SetAttribute("sizes", sizes)
+	}
+	return l
+}
+
+// AddScript adds a script to the head. If the script has already been added, that one is returned instead.
+func (pm *PageHead) AddScript(source interface{}) *ScriptElement {
+	for _, s := range pm.Scripts {
+		if sameSource(s.Src, source) {
+			return s
+		}
+	}
+	s := newScriptElement(source)
+	pm.Scripts = append(pm.Scripts, s)
+	return s
+}
+
+func newScriptElement(source interface{}) *ScriptElement {
+	return &ScriptElement{
+		PageElement: &PageElement{
+			AttibutingElement: &AttibutingElement{},
+			Kind:              ElementTag_Closing,
+			ElementName:       "script",
+		},
+		Src: source,
+	}
+}
+
+// AddStyle adds an inline stylesheet to the head. The same css is only added once.
+func (pm *PageHead) AddStyle(css string) *PageHead {
+	for _, s := range pm.Styles {
+		if s == css {
+			return pm
+		}
+	}
+	pm.Styles = append(pm.Styles, css)
+	return pm
+}
+
+func sameSource(a, b interface{}) bool {
+	return getInterfaceString(a) == getInterfaceString(b)
+}
+
 func (pm *PageHead) Write(ctx PageContext, w io.Writer) error {
 	sb := strings.Builder{}
+	writeElement := func(e RenderableElement) {
+		html, err := RenderPageElement(ctx, e)
+		if err != nil {
+			logger.WithError(err).Errorf("failed to render %v", e.GetElementName(ctx))
+			return
+		}
+		sb.WriteString(html)
+	}
 	{
 		sb.WriteString("<head>")
-		for _, l := range pm.Links {
-			html, err := RenderPageElement(ctx, l)
-			if err != nil {
-				logger.WithError(err).Errorf("failed to render %v", l.LinkType)
-				continue
+		// the charset has to come first
+		for _, m := range pm.Metas {
+			if metaKey(m.Attributes) == "charset" {
+				writeElement(m)
 			}
-
-			sb.WriteString(html)
-
+		}
+		if pm.Title != "" {
+			sb.WriteString("<title>" + html.EscapeString(pm.Title) + "</title>")
+		}
+		for _, m := range pm.Metas {
+			if metaKey(m.Attributes) != "charset" {
+				writeElement(m)
+			}
+		}
+		if pm.Canonical != nil {
+			writeElement(&LinkElement{
+				PageElement: &PageElement{Kind: ElementTag_NoClose, ElementName: "link"},
+				LinkType:    string(canonical),
+				Href:        pm.Canonical,
+			})
+		}
+		for _, l := range pm.Links {
+			writeElement(l)
+		}
+		for _, s := range pm.Styles {
+			sb.WriteString("<style" + nonceAttribute(ctx) + ">" + s + "</style>")
+		}
+		for _, s := range pm.Scripts {
+			writeElement(s)
 		}
 		sb.WriteString("</head>")
 	}
@@ -0,0 +1,55 @@
+package register
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type headPage struct {
+	testPage
+	head func(m *PageHead) *PageHead
+}
+
+func (hp *headPage) QueryBehaviour(ctx PageContext, b Behaviour) Behaviour {
+	return b.WithMetaHandling(hp.head)
+}
+
+func Test_PageHead(t *testing.T) {
+	site := NewSite()
+	site.SetSiteName("Ops")
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "jobs", &headPage{testPage: testPage{name: "jobs"}, head: func(m *PageHead) *PageHead {
+		m.AddMeta(map[string]interface{}{"name": "description", "content": "first"})
+		m.AddMeta(map[string]interface{}{"name": "description", "content": "second"})
+		m.AddScript("/app.js").Defer()
+		m.AddScript("/app.js").Module()
+		m.AddStyle("p{color:red}")
+		m.AddStyle("p{color:red}")
+		m.AddFavicon("/icon.png", "32x32")
+		m.AddLink(Icon, "/icon.png")
+		m.SetCanonical("https://example.com/jobs")
+		return m
+	}})
+	site.RegisterPage(RootPageId, "titled", &headPage{testPage: testPage{name: "titled"}, head: func(m *PageHead) *PageHead {
+		return m.SetTitle("Custom <title>")
+	}})
+	h := site.Handler()
+
+	_, body := getBody(t, h, "/jobs")
+	assert.True(t, strings.HasPrefix(body, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>jobs - Ops</title>"), body)
+	assert.NotContains(t, body, "first")
+	assert.Contains(t, body, "second")
+	assert.Equal(t, 1, strings.Count(body, "<script"))
+	assert.Contains(t, body, `defer="defer"`)
+	assert.Contains(t, body, `type="module"`)
+	assert.Equal(t, 1, strings.Count(body, "<style nonce="))
+	assert.Equal(t, 1, strings.Count(body, `href="/icon.png"`))
+	assert.Contains(t, body, `href="https://example.com/jobs"`)
+
+	code, body := getBody(t, h, "/titled")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "<title>Custom &lt;title&gt;</title>")
+}
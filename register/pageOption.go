@@ -0,0 +1,88 @@
+package register
+
+// PageOption configures a page as it is registered (i.e: WithWeight, Hidden or RequireRoles)
+type PageOption interface {
+	apply(info *registeredPageInfo)
+}
+
+type pageOptionFunc func(info *registeredPageInfo)
+
+func (f pageOptionFunc) apply(info *registeredPageInfo) {
+	f(info)
+}
+
+// navOptions control how a page is presented in menus
+type navOptions struct {
+	weight      int
+	title       string
+	icon        string
+	hidden      bool
+	group       string
+	description string
+}
+
+// WithWeight sets where the page sits amongst its siblings. Lower weights are shown first.
+func WithWeight(weight int) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.weight = weight
+	})
+}
+
+// WithTitle sets the title of the page in menus. If not set, the name of the page is used.
+func WithTitle(title string) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.title = title
+	})
+}
+
+// WithIcon sets the icon shown with the page. How it is used is up to the layout (i.e: a css class or an image url).
+func WithIcon(icon string) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.icon = icon
+	})
+}
+
+// Hidden keeps the page out of menus. It can still be opened via its url.
+func Hidden() PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.hidden = true
+	})
+}
+
+// WithNavGroup places the page in a named group so layouts can show related pages together.
+func WithNavGroup(group string) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.group = group
+	})
+}
+
+// WithDescription sets a short explanation of the page, such as for a tooltip.
+func WithDescription(description string) PageOption {
+	return pageOptionFunc(func(info *registeredPageInfo) {
+		info.nav.description = description
+	})
+}
+
+// PageGroup is a set of pages that share a nav group
+type PageGroup struct {
+	// Name of the group. Empty for pages that are not grouped
+	Name  string
+	Pages []PageStructure
+}
+
+// GroupPages splits pages into their nav groups. Groups are returned in the order their first page appears, so sorting the pages first
+// orders the groups by their lightest page.
+func GroupPages(pages []PageStructure) []PageGroup {
+	var groups []PageGroup
+	index := map[string]int{}
+	for _, p := range pages {
+		ix, ok := index[p.NavGroup()]
+		if !ok {
+			ix = len(groups)
+			index[p.NavGroup()] = ix
+			groups = append(groups, PageGroup{Name: p.NavGroup()})
+		}
+		groups[ix].Pages = append(groups[ix].Pages, p)
+	}
+	return groups
+}
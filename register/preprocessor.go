@@ -0,0 +1,198 @@
+package register
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+)
+
+// Redirect can be returned as the result of a preprocessor to send the browser elsewhere instead of rendering the page.
+type Redirect struct {
+	// Where to send the browser: a Page, a url string or a *url.URL
+	Target interface{}
+	// The status code to redirect with (default 302 Found)
+	StatusCode int
+}
+
+func (rd Redirect) redirect(ctx *pageContext, w http.ResponseWriter, r *http.Request) {
+	u, err := ctx.ResolveUrl(rd.Target)
+	if err != nil {
+		ctx.site.renderError(ctx, w, r, fmt.Errorf("failed to resolve redirect: %w", err))
+		return
+	}
+	code := rd.StatusCode
+	if code == 0 {
+		code = http.StatusFound
+	}
+	http.Redirect(w, r, u.String(), code)
+}
+
+// AddGlobalPreprocessor adds a preprocessor to the default site that is evaluated before every page
+func AddGlobalPreprocessor(fn func(Page, PageContext) PagePreprocessResult) {
+	globalregister.AddGlobalPreprocessor(fn)
+}
+
+// AddPagePreprocessor adds a preprocessor to a page of the default site
+func AddPagePreprocessor(page Page, fn func(Page, PageContext) PagePreprocessResult, applyChildren bool) error {
+	return globalregister.AddPagePreprocessor(page, fn, applyChildren)
+}
+
+// AddGlobalPreprocessor adds a preprocessor that is evaluated before every page of the site. Global preprocessors are evaluated after
+// the preprocessors of the page.
+func (s *Site) AddGlobalPreprocessor(fn func(Page, PageContext) PagePreprocessResult) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.globalPreprocessors = append(s.globalPreprocessors, &pagePreprocessor{
+		preprocessor:  fn,
+		applyChildren: true,
+	})
+}
+
+// AddPagePreprocessor adds a preprocessor that is evaluated before the page is rendered. If applyChildren is set, it is also evaluated
+// for all children of the page. The page must already be registered.
+func (s *Site) AddPagePreprocessor(page Page, fn func(Page, PageContext) PagePreprocessResult, applyChildren bool) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	info := s.pageRegister[page]
+	if info == nil {
+		return fmt.Errorf("cannot add preprocessor: page %s has not been registered", page.Name())
+	}
+	info.preprocessors = append(info.preprocessors, &pagePreprocessor{
+		preprocessor:  fn,
+		applyChildren: applyChildren,
+	})
+	if s.compiled {
+		s.publish()
+	}
+	return nil
+}
+
+// preprocess evaluates the preprocessors of the page, then its parents, then the global preprocessors. The last result set wins.
+func (s *Site) preprocess(p Page, ctx PageContext) interface{} {
+	var ppResult interface{}
+	evaluate := func(pp *pagePreprocessor) bool {
+		res := pp.preprocessor(p, ctx)
+		if res.Result != nil {
+			ppResult = res.Result
+		}
+		return res.HaltPreprocessing
+	}
+	isparent := false
+	for info := s.treeFor(ctx).pages[p]; info != nil; info = info.resolvedParent {
+		for _, pp := range info.preprocessors {
+			if (!isparent || pp.applyChildren) && evaluate(pp) {
+				return ppResult
+			}
+		}
+		isparent = true
+	}
+	for _, pp := range s.globalPreprocessors {
+		if evaluate(pp) {
+			break
+		}
+	}
+	return ppResult
+}
+
+const pageErrorKey = "GOOEY_pageerror"
+
+// GetPageError returns the error that is being rendered by the error page. Nil if there is no error
+func GetPageError(ctx PageContext) error {
+	if v, ok := ctx.RequestCache().GetValue(pageErrorKey); ok {
+		return v.(error)
+	}
+	return nil
+}
+
+// renderError responds with the error page of the site. The status of the response is the status of the error (see StatusCodeOf).
+func (s *Site) renderError(ctx *pageContext, w http.ResponseWriter, r *http.Request, err error) {
+	logError(err)
+	if GetPageError(ctx) != nil {
+		// the error page (or the layout) has failed as well
+		io.WriteString(w, html.EscapeString(ErrorMessage(ctx, err)))
+		return
+	}
+	ctx.RequestCache().SetValue(pageErrorKey, err)
+	if !headersSent(w) {
+		w.WriteHeader(StatusCodeOf(err))
+	}
+	s.renderPage(ctx, w, r, s.errorPageFor(err))
+}
+
+// servePage calls the handler of the page. If the page returns an error or panics, the error page is written in its place.
+func (s *Site) servePage(ctx PageContext, w http.ResponseWriter, r *http.Request, page Page) interface{} {
+	res, err := callHandler(ctx, w, r, page)
+	if err == nil {
+		e, ok := res.(error)
+		if !ok {
+			return res
+		}
+		err = e
+	}
+	logError(err)
+	if GetPageError(ctx) != nil {
+		// this is the error page failing
+		io.WriteString(w, html.EscapeString(ErrorMessage(ctx, err)))
+		return nil
+	}
+	ctx.RequestCache().SetValue(pageErrorKey, err)
+	if !headersSent(w) {
+		w.WriteHeader(StatusCodeOf(err))
+	}
+	s.servePage(ctx, w, r, s.errorPageFor(err))
+	return nil
+}
+
+// callHandler calls the handler of the page, turning a panic into an error
+func callHandler(ctx PageContext, w http.ResponseWriter, r *http.Request, page Page) (res interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = Recovered(rec)
+		}
+	}()
+	return page.Handler(ctx, w, r), nil
+}
+
+func (s *Site) errorPageFor(err error) Page {
+	if StatusCodeOf(err) == http.StatusNotFound && s.corePages.NotFoundPage != nil {
+		return s.corePages.NotFoundPage
+	}
+	if s.corePages.ErrorPage != nil {
+		return s.corePages.ErrorPage
+	}
+	return &errorPage{}
+}
+
+func logError(err error) {
+	log := logger.WithError(err)
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		log = log.WithField("stack", string(pe.Stack))
+	}
+	if StatusCodeOf(err) >= http.StatusInternalServerError {
+		log.Error("failed to handle page")
+	} else {
+		log.Debug("page returned an error")
+	}
+}
+
+type errorPage struct {
+}
+
+func (ep *errorPage) Name() string {
+	return "Error"
+}
+
+func (ep *errorPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	details := GetErrorDetails(ctx)
+	if details == nil {
+		details = &ErrorDetails{Message: "An error occurred"}
+	}
+	fmt.Fprintf(w, `<div class="GOOEY GOOEY_error">%s</div>`, html.EscapeString(details.Message))
+	if details.Detail != "" {
+		fmt.Fprintf(w, `<pre class="GOOEY GOOEY_errordetail">%s</pre>`, html.EscapeString(details.Detail))
+	}
+	return nil
+}
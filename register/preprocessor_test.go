@@ -0,0 +1,65 @@
+package register
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Preprocessors(t *testing.T) {
+	site := NewSite()
+	root := &testPage{name: "root"}
+	section := &testPage{name: "section"}
+	child := &testPage{name: "child"}
+	maintenance := &testPage{name: "maintenance"}
+	site.RegisterPage(nil, RootPageId, root)
+	site.RegisterPage(RootPageId, "section", section)
+	site.RegisterPage(section, "child", child)
+	site.RegisterPage(RootPageId, "maintenance", maintenance, Hidden())
+	site.Compile()
+
+	assert.NoError(t, site.AddPagePreprocessor(section, func(p Page, ctx PageContext) PagePreprocessResult {
+		switch url.Values(ctx.GetContextData()).Get("mode") {
+		case "redirect":
+			return PagePreprocessResult{Result: Redirect{Target: root}}
+		case "error":
+			return PagePreprocessResult{Result: errors.New("<broken>")}
+		case "halt":
+			return PagePreprocessResult{HaltPreprocessing: true}
+		}
+		return PagePreprocessResult{}
+	}, true))
+	site.AddGlobalPreprocessor(func(p Page, ctx PageContext) PagePreprocessResult {
+		if ctx.GetContextData()["maintenance"] != nil {
+			return PagePreprocessResult{Result: maintenance}
+		}
+		return PagePreprocessResult{}
+	})
+	assert.Error(t, site.AddPagePreprocessor(&testPage{name: "unregistered"}, nil, false))
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/section/child?mode=redirect", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "http://example.com/", rec.Header().Get("Location"))
+
+	code, body := getBody(t, h, "/section?mode=error")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.NotContains(t, body, "broken")
+	site.SetDevMode(true)
+	_, body = getBody(t, h, "/section?mode=error")
+	assert.Contains(t, body, "&lt;broken&gt;")
+	site.SetDevMode(false)
+
+	code, body = getBody(t, h, "/section/child?maintenance")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "page:maintenance")
+
+	// halting stops the global preprocessors as well
+	_, body = getBody(t, h, "/section/child?maintenance&mode=halt")
+	assert.Contains(t, body, "page:child")
+}
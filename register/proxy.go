@@ -0,0 +1,130 @@
+package register
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ProxyPolicy determines when the forwarding headers added by reverse proxies (Forwarded, X-Forwarded-Proto, X-Forwarded-Host and
+// X-Forwarded-Prefix) can be trusted when building absolute urls. Without a policy, these headers are ignored.
+type ProxyPolicy struct {
+	// If true, forwarding headers are trusted regardless of where the request came from. Only use this when the site cannot be reached
+	// except through the proxy.
+	TrustAll bool
+	// The networks that forwarding headers will be trusted from.
+	TrustedNetworks []*net.IPNet
+}
+
+// TrustProxies creates a policy that trusts forwarding headers from the given addresses. Each address can either be a single IP or a CIDR range.
+func TrustProxies(addresses ...string) (*ProxyPolicy, error) {
+	pp := &ProxyPolicy{}
+	for _, a := range addresses {
+		a = strings.TrimSpace(a)
+		if !strings.Contains(a, "/") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %s", a)
+			}
+			if ip.To4() != nil {
+				a += "/32"
+			} else {
+				a += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %s: %w", a, err)
+		}
+		pp.TrustedNetworks = append(pp.TrustedNetworks, n)
+	}
+	return pp, nil
+}
+
+// IsTrusted returns true if the forwarding headers of the request can be used
+func (pp *ProxyPolicy) IsTrusted(r *http.Request) bool {
+	if pp == nil {
+		return false
+	}
+	if pp.TrustAll {
+		return true
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range pp.TrustedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetProxyPolicy sets the proxy policy of the default site
+func SetProxyPolicy(pp *ProxyPolicy) {
+	globalregister.SetProxyPolicy(pp)
+}
+
+// SetProxyPolicy sets the policy used to decide if forwarding headers from reverse proxies should be trusted
+func (s *Site) SetProxyPolicy(pp *ProxyPolicy) {
+	s.proxyPolicy = pp
+}
+
+// requestOrigin is where the client believes the site lives
+type requestOrigin struct {
+	scheme string
+	host   string
+	// the path prefix added by a proxy. This does not include the path prefix of the site itself
+	prefix string
+}
+
+func (s *Site) resolveOrigin(r *http.Request) requestOrigin {
+	o := requestOrigin{
+		scheme: "http",
+		host:   r.Host,
+	}
+	if r.TLS != nil {
+		o.scheme = "https"
+	}
+	if !s.proxyPolicy.IsTrusted(r) {
+		return o
+	}
+	if fwd := r.Header.Get("Forwarded"); fwd != "" {
+		// we only care about the first (client facing) proxy
+		first := strings.SplitN(fwd, ",", 2)[0]
+		for _, pair := range strings.Split(first, ";") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			val := strings.Trim(parts[1], `"`)
+			switch strings.ToLower(parts[0]) {
+			case "proto":
+				o.scheme = strings.ToLower(val)
+			case "host":
+				o.host = val
+			}
+		}
+	} else {
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto != "" {
+			o.scheme = strings.ToLower(proto)
+		}
+		if host := firstHeaderValue(r, "X-Forwarded-Host"); host != "" {
+			o.host = host
+		}
+	}
+	if prefix := firstHeaderValue(r, "X-Forwarded-Prefix"); prefix != "" {
+		o.prefix = strings.TrimRight("/"+strings.TrimLeft(prefix, "/"), "/")
+	}
+	return o
+}
+
+func firstHeaderValue(r *http.Request, name string) string {
+	return strings.TrimSpace(strings.SplitN(r.Header.Get(name), ",", 2)[0])
+}
@@ -0,0 +1,87 @@
+package register
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ResolveOrigin(t *testing.T) {
+	trusted, err := TrustProxies("10.0.0.0/8", "192.168.1.1")
+	assert.NoError(t, err)
+	_, err = TrustProxies("not an ip")
+	assert.Error(t, err)
+
+	tests := []struct {
+		name       string
+		policy     *ProxyPolicy
+		remoteAddr string
+		headers    map[string]string
+		tls        bool
+		expected   requestOrigin
+	}{
+		{
+			name:     "no proxy",
+			expected: requestOrigin{scheme: "http", host: "example.com"},
+		},
+		{
+			name:     "tls",
+			tls:      true,
+			expected: requestOrigin{scheme: "https", host: "example.com"},
+		},
+		{
+			name:       "untrusted headers are ignored",
+			remoteAddr: "8.8.8.8:1234",
+			policy:     trusted,
+			headers:    map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"},
+			expected:   requestOrigin{scheme: "http", host: "example.com"},
+		},
+		{
+			name:       "x-forwarded",
+			remoteAddr: "10.1.2.3:1234",
+			policy:     trusted,
+			headers: map[string]string{
+				"X-Forwarded-Proto":  "https, http",
+				"X-Forwarded-Host":   "public.com",
+				"X-Forwarded-Prefix": "/ops/",
+			},
+			expected: requestOrigin{scheme: "https", host: "public.com", prefix: "/ops"},
+		},
+		{
+			name:       "forwarded",
+			remoteAddr: "192.168.1.1:1234",
+			policy:     trusted,
+			headers: map[string]string{
+				"Forwarded":         `for=192.0.2.60;proto=https;host="public.com", for=10.0.0.1`,
+				"X-Forwarded-Proto": "http",
+			},
+			expected: requestOrigin{scheme: "https", host: "public.com"},
+		},
+		{
+			name:     "trust all",
+			policy:   &ProxyPolicy{TrustAll: true},
+			headers:  map[string]string{"X-Forwarded-Proto": "https"},
+			expected: requestOrigin{scheme: "https", host: "example.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := NewSite()
+			site.SetProxyPolicy(tt.policy)
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if tt.remoteAddr != "" {
+				r.RemoteAddr = tt.remoteAddr
+			}
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.expected, site.resolveOrigin(r))
+		})
+	}
+}
@@ -1,120 +1,425 @@
 package register
 
 import (
+	"encoding"
+	"encoding/json"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-	"unsafe"
+	"time"
 )
 
-func unmarshallFormData(data map[string][]string, target interface{}) error {
-	// first, we need to inspect the thing we are unmarshalling into
+// Values are bound to the exported fields of a struct by name. The name of a field can be changed with either a `form:"name"` tag or
+// the name rule of a gooey tag (i.e: `gooey:"required,name=email"`). Fields tagged `form:"-"` are never bound.
+// Names are matched ignoring case. Fields of nested structs are named with a path (i.e: Address.Street), and slices of structs with an
+// index (i.e: Items.0.Name), which only decides the order of the items. Multiple values for the same name fill a slice.
 
+// the most that will be held in memory when parsing a multipart form. Anything larger goes to temporary files
+const maxMultipartMemory = 32 << 20
+
+// the highest index a slice of structs can be given. The request decides the indexes, so they can't be trusted to be sensible
+const maxSliceIndex = 1000
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	fileHeaderType      = reflect.TypeOf(&multipart.FileHeader{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// the layouts times are accepted in. The html date and time inputs do not include seconds or a time zone
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// FieldError is a value that could not be bound to a field
+type FieldError struct {
+	// the name the value was given under (i.e: Address.Street)
+	Field string
+	Value string
+	Err   error
+}
+
+func (fe *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", fe.Field, fe.Err)
+}
+
+func (fe *FieldError) Unwrap() error {
+	return fe.Err
+}
+
+// BindError is returned when some values could not be bound. Every field that failed is reported, and all other fields are still set.
+type BindError struct {
+	Fields []*FieldError
+}
+
+func (be *BindError) Error() string {
+	msgs := make([]string, 0, len(be.Fields))
+	for _, fe := range be.Fields {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("%d field(s) could not be bound: %s", len(be.Fields), strings.Join(msgs, "; "))
+}
+
+// Field returns the error for the named field. Nil if the field was bound.
+func (be *BindError) Field(name string) *FieldError {
+	for _, fe := range be.Fields {
+		if strings.EqualFold(fe.Field, name) {
+			return fe
+		}
+	}
+	return nil
+}
+
+// BindRequest fills v from the request: the query string, and the body if it is a form post, a multipart form or JSON.
+// Values in the body take precedence over the query string.
+func BindRequest(r *http.Request, v interface{}) error {
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		// the body might not be an object (i.e: a list), in which case the query string has nowhere to go
+		if rt := reflect.TypeOf(v); rt != nil && indirectType(rt).Kind() == reflect.Struct {
+			if err := Bind(r.URL.Query(), v); err != nil {
+				return err
+			}
+		}
+		if r.Body == nil || r.Body == http.NoBody {
+			return nil
+		}
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			return fmt.Errorf("failed to decode json body: %w", err)
+		}
+		return nil
+	case ct == "multipart/form-data":
+		if r.MultipartForm == nil {
+			if err := r.ParseMultipartForm(maxMultipartMemory); err != nil {
+				return fmt.Errorf("failed to parse multipart form: %w", err)
+			}
+		}
+		return bind(r.Form, r.MultipartForm.File, v)
+	default:
+		if err := r.ParseForm(); err != nil {
+			return fmt.Errorf("failed to parse form: %w", err)
+		}
+		return bind(r.Form, nil, v)
+	}
+}
+
+// Bind fills v, which must be a pointer to a struct or a map, from the values (i.e: url.Values). If any values cannot be converted to the
+// type of their field, a *BindError is returned.
+func Bind(data map[string][]string, v interface{}) error {
+	return bind(data, nil, v)
+}
+
+func bind(data map[string][]string, files map[string][]*multipart.FileHeader, target interface{}) error {
 	switch t := target.(type) {
 	case *map[string]interface{}:
+		if *t == nil {
+			*t = map[string]interface{}{}
+		}
 		for k, v := range data {
 			(*t)[k] = v
 		}
 		return nil
+	case *map[string][]string:
+		if *t == nil {
+			*t = map[string][]string{}
+		}
+		for k, v := range data {
+			(*t)[k] = v
+		}
+		return nil
+	}
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		// the target should ALWAYS be a pointer to the real thing
+		return fmt.Errorf("cannot bind to %T: expected a pointer", target)
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("cannot bind to %T: expected a struct or a map", target)
+	}
+	b := &binder{
+		values: map[string][]string{},
+		files:  map[string][]*multipart.FileHeader{},
+	}
+	// names are matched ignoring case
+	for k, v := range data {
+		k = strings.ToLower(k)
+		b.values[k] = append(b.values[k], v...)
+	}
+	for k, v := range files {
+		k = strings.ToLower(k)
+		b.files[k] = append(b.files[k], v...)
+	}
+	b.bindStruct("", rv)
+	if len(b.errs) > 0 {
+		return &BindError{Fields: b.errs}
+	}
+	return nil
+}
+
+type binder struct {
+	values map[string][]string
+	files  map[string][]*multipart.FileHeader
+	errs   []*FieldError
+}
+
+// hasPrefix returns true if any value has a name beneath the path
+func (b *binder) hasPrefix(path string) bool {
+	prefix := strings.ToLower(path) + "."
+	for k := range b.values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for k := range b.files {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
 	}
-	rtv := reflect.ValueOf(target)
-	if rtv.Kind() != reflect.Ptr {
-		// the target should ALWAYS be a pointer to the real thing. Ignore any alternatives
-		return fmt.Errorf("expected a pointer, but got %t instead", target)
-	}
-	// so, lets try to process this
-	realTargetValue := rtv.Elem()
-	realTargetType := realTargetValue.Type()
-	// this will do much more forgiving lookups on the map
-	for ix := 0; ix < realTargetType.NumField(); ix++ {
-		field := realTargetType.Field(ix)
-
-		valArr, ok := findMapValue(data, field.Name)
-
-		if ok {
-			// now we need to try and set it:
-			fieldVal := realTargetValue.Field(ix)
-			realType := field.Type
-			for realType.Kind() == reflect.Ptr {
-				realType = realType.Elem()
+	return false
+}
+
+// indexes returns the indexes given beneath the path (i.e: 0 and 1 for Items.0.Name and Items.1.Name), in order
+func (b *binder) indexes(path string) []int {
+	prefix := strings.ToLower(path) + "."
+	found := map[int]bool{}
+	for k := range b.values {
+		if rest := strings.TrimPrefix(k, prefix); len(rest) < len(k) {
+			if ix, err := strconv.Atoi(strings.SplitN(rest, ".", 2)[0]); err == nil && ix >= 0 {
+				found[ix] = true
 			}
-			setFieldValue(valArr, fieldVal, realType)
 		}
 	}
+	ret := make([]int, 0, len(found))
+	for ix := range found {
+		ret = append(ret, ix)
+	}
+	sort.Ints(ret)
+	return ret
+}
 
-	return nil
+func fieldName(f reflect.StructField) (string, bool) {
+	if name, ok := f.Tag.Lookup("form"); ok {
+		if name = strings.Split(name, ",")[0]; name == "-" {
+			return "", false
+		} else if name != "" {
+			return name, true
+		}
+	}
+	for _, rule := range strings.Split(f.Tag.Get("gooey"), ",") {
+		if parts := strings.SplitN(rule, "=", 2); len(parts) == 2 && parts[0] == "name" {
+			return parts[1], true
+		}
+	}
+	return f.Name, true
+}
+
+func (b *binder) bindStruct(prefix string, rv reflect.Value) {
+	rt := rv.Type()
+	for ix := 0; ix < rt.NumField(); ix++ {
+		f := rt.Field(ix)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		fv := rv.Field(ix)
+		if f.Anonymous && f.Tag.Get("form") == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			// embedded structs share the names of the struct they are embedded in
+			if f.Type.Kind() == reflect.Ptr && fv.IsNil() {
+				if !fv.CanSet() {
+					continue
+				}
+				fv.Set(reflect.New(f.Type.Elem()))
+			}
+			b.bindStruct(prefix, reflect.Indirect(fv))
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		b.bindField(path, fv)
+	}
 }
 
-func setFieldValue(srcVal []string, rv reflect.Value, rt reflect.Type) {
-	ref := getReflectValue(srcVal, rv, rt)
-	if ref != nil {
-		rv.Set(*ref)
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	return t
 }
 
-func getReflectValue(srcVal []string, rv reflect.Value, rt reflect.Type) *reflect.Value {
-	var newVal interface{}
-	switch rt.Kind() {
-	case reflect.Array, reflect.Slice:
-		// we have an array. We need to get to the arrays REAL value
-		// this recusive technique works great because our code works for single values and arrays of values
-		arrayType := rt.Elem()
-		// now we need some kind of reflect value that will take our array element
-		for _, sv := range srcVal {
+// isScalar returns true if the type is set from a single value rather than having fields of its own
+func isScalar(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType) || t.Kind() != reflect.Struct
+}
 
-			newRefVal := getReflectValue([]string{sv}, reflect.New(arrayType).Elem(), arrayType)
-			rv.Set(reflect.Append(rv, *newRefVal))
+func (b *binder) bindField(path string, fv reflect.Value) {
+	ft := indirectType(fv.Type())
+	key := strings.ToLower(path)
+	switch {
+	case fv.Type() == fileHeaderType:
+		if fhs := b.files[key]; len(fhs) > 0 {
+			fv.Set(reflect.ValueOf(fhs[0]))
 		}
-		return &rv
-	case reflect.Int:
-		if len(srcVal) != 1 {
-			return nil
+	case fv.Type() == reflect.SliceOf(fileHeaderType):
+		if fhs := b.files[key]; len(fhs) > 0 {
+			fv.Set(reflect.ValueOf(fhs))
 		}
-		newInt, _ := strconv.ParseInt(srcVal[0], 10, 64)
-		newVal = int(newInt)
-	case reflect.Int64:
-		if len(srcVal) != 1 {
-			return nil
+	case !isScalar(ft):
+		if b.hasPrefix(path) {
+			b.bindStruct(path, allocate(fv))
 		}
-		newInt, _ := strconv.ParseInt(srcVal[0], 10, 64)
-		newVal = int64(newInt)
-	case reflect.Int32:
-		if len(srcVal) != 1 {
-			return nil
+	case ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 && !reflect.PtrTo(ft).Implements(textUnmarshalerType):
+		b.bindSlice(path, fv)
+	default:
+		vals, ok := b.values[key]
+		if !ok || len(vals) == 0 {
+			return
 		}
-		newInt, _ := strconv.ParseInt(srcVal[0], 10, 32)
-		newVal = int32(newInt)
-	case reflect.String:
-		if len(srcVal) != 1 {
-			return nil
+		b.setValue(path, fv, vals[0])
+	}
+}
+
+func (b *binder) bindSlice(path string, fv reflect.Value) {
+	st := indirectType(fv.Type())
+	if !isScalar(indirectType(st.Elem())) {
+		// slices of structs are given by index. The indexes only give the order, so gaps are left out
+		var ixs []int
+		for _, ix := range b.indexes(path) {
+			if ix > maxSliceIndex {
+				ixPath := fmt.Sprintf("%s.%d", path, ix)
+				b.errs = append(b.errs, &FieldError{Field: ixPath, Err: fmt.Errorf("index cannot be greater than %d", maxSliceIndex)})
+				continue
+			}
+			ixs = append(ixs, ix)
+		}
+		if len(ixs) == 0 {
+			return
+		}
+		slice := reflect.MakeSlice(st, len(ixs), len(ixs))
+		for i, ix := range ixs {
+			b.bindStruct(fmt.Sprintf("%s.%d", path, ix), allocate(slice.Index(i)))
+		}
+		allocate(fv).Set(slice)
+		return
+	}
+	vals, ok := b.values[strings.ToLower(path)]
+	if !ok {
+		return
+	}
+	slice := reflect.MakeSlice(st, 0, len(vals))
+	for _, s := range vals {
+		ev := reflect.New(st.Elem()).Elem()
+		if !b.setValue(path, ev, s) {
+			continue
 		}
-		sHolder := string(srcVal[0])
-		newVal = sHolder
+		slice = reflect.Append(slice, ev)
 	}
-	var ref reflect.Value
+	allocate(fv).Set(slice)
+}
 
-	if rv.Kind() == reflect.Ptr {
-		if rt.Kind() == reflect.String {
-			// we have to treat string pointers a little differet, as the method in the "else" completely falls over with a pointer to a string.
-			// (i think the string gets GCd up or something, I'm kind of at the trial-and-error phase of this part of the code, so this works)
-			strVal := newVal.(string)
-			ref = reflect.ValueOf(&strVal)
-		} else {
-			ref = reflect.NewAt(rv.Type(), unsafe.Pointer(&newVal)).Elem()
+// allocate follows (and if required creates) any pointers to the underlying value
+func allocate(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
 		}
+		v = v.Elem()
+	}
+	return v
+}
 
-	} else {
-		ref = reflect.ValueOf(newVal)
+// setValue converts the value to the type of the field and sets it. Any problems are recorded against the field.
+func (b *binder) setValue(path string, fv reflect.Value, s string) bool {
+	if s == "" && indirectType(fv.Type()).Kind() != reflect.String {
+		// empty inputs leave the field as it was
+		return true
+	}
+	nv := reflect.New(indirectType(fv.Type()))
+	if err := convertValue(s, nv.Elem()); err != nil {
+		b.errs = append(b.errs, &FieldError{Field: path, Value: s, Err: err})
+		return false
 	}
-	return &ref
+	allocate(fv).Set(nv.Elem())
+	return true
 }
 
-func findMapValue(data map[string][]string, key string) ([]string, bool) {
-	for k, v := range data {
-		if strings.EqualFold(k, key) {
-			return v, true
+func convertValue(s string, v reflect.Value) error {
+	switch v.Type() {
+	case timeType:
+		// time.Time is a TextUnmarshaler, but only accepts RFC 3339
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				v.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid time", s)
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
 		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return tu.UnmarshalText([]byte(s))
 	}
-	return nil, false
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "on" {
+			// how browsers post a checked checkbox without a value
+			v.SetBool(true)
+			return nil
+		}
+		bv, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(bv)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		iv, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(iv)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uv, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(uv)
+	case reflect.Float32, reflect.Float64:
+		fv, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(fv)
+	case reflect.Slice:
+		// only []byte gets here
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("cannot bind to a field of type %s", v.Type())
+	}
+	return nil
 }
@@ -1,7 +1,16 @@
 package register
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -12,9 +21,9 @@ func Test_UnmarhsallMap(t *testing.T) {
 		"second": []string{"two, three"},
 	}
 	target := map[string]interface{}{}
-	err := unmarshallFormData(formData, target)
+	err := Bind(formData, target)
 	assert.Error(t, err)
-	err = unmarshallFormData(formData, &target)
+	err = Bind(formData, &target)
 	assert.Nil(t, err)
 	assert.Equal(t, target["first"], []string{"1"})
 	assert.Equal(t, target["second"], []string{"two, three"})
@@ -43,7 +52,7 @@ func Test_UnmarhsallStruct(t *testing.T) {
 		StringArrayOne      []string
 		StringPtr           *string
 	}
-	err := unmarshallFormData(formData, &target)
+	err := Bind(formData, &target)
 	assert.Nil(t, err)
 	assert.Equal(t, target.IntegerVal, 1)
 	assert.Equal(t, target.IntegerArrayMany, []int{1, 2, 3})
@@ -53,3 +62,148 @@ func Test_UnmarhsallStruct(t *testing.T) {
 	assert.Equal(t, target.StringArrayOne, []string{"one"})
 	assert.Equal(t, *target.StringPtr, "second")
 }
+
+type level int
+
+func (l *level) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %s", b)
+	}
+	return nil
+}
+
+type bindAddress struct {
+	Street string
+	Zip    uint16
+}
+
+type bindTarget struct {
+	Name     string `form:"full_name"`
+	Email    string `gooey:"required,name=mail"`
+	Skipped  string `form:"-"`
+	Age      int8
+	Ratio    float32
+	Active   bool
+	Started  time.Time
+	Timeout  time.Duration
+	Level    level
+	Tags     []string
+	Address  *bindAddress
+	Items    []bindAddress
+	Retries  *uint
+	internal string
+}
+
+func Test_BindTypes(t *testing.T) {
+	var target bindTarget
+	err := Bind(url.Values{
+		"full_name":      {"Jo"},
+		"mail":           {"jo@example.com"},
+		"skipped":        {"no"},
+		"age":            {"42"},
+		"ratio":          {"0.5"},
+		"active":         {"on"},
+		"started":        {"2023-01-02T15:04"},
+		"timeout":        {"1m30s"},
+		"level":          {"high"},
+		"tags":           {"a", "b"},
+		"address.street": {"Main St"},
+		"address.zip":    {"3000"},
+		"items.1.street": {"Second"},
+		"items.0.street": {"First"},
+		"retries":        {"3"},
+		"internal":       {"no"},
+	}, &target)
+	assert.NoError(t, err)
+	assert.Equal(t, "Jo", target.Name)
+	assert.Equal(t, "jo@example.com", target.Email)
+	assert.Empty(t, target.Skipped)
+	assert.Equal(t, int8(42), target.Age)
+	assert.Equal(t, float32(0.5), target.Ratio)
+	assert.True(t, target.Active)
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 0, 0, time.UTC), target.Started)
+	assert.Equal(t, 90*time.Second, target.Timeout)
+	assert.Equal(t, level(2), target.Level)
+	assert.Equal(t, []string{"a", "b"}, target.Tags)
+	assert.Equal(t, &bindAddress{Street: "Main St", Zip: 3000}, target.Address)
+	assert.Equal(t, []bindAddress{{Street: "First"}, {Street: "Second"}}, target.Items)
+	assert.Equal(t, uint(3), *target.Retries)
+	assert.Empty(t, target.internal)
+}
+
+func Test_BindErrors(t *testing.T) {
+	var target bindTarget
+	err := Bind(url.Values{
+		"full_name":   {"Jo"},
+		"age":         {"300"},
+		"level":       {"medium"},
+		"address.zip": {"-1"},
+		"timeout":     {""},
+	}, &target)
+	var be *BindError
+	assert.True(t, errors.As(err, &be))
+	assert.Len(t, be.Fields, 3)
+	assert.NotNil(t, be.Field("Age"))
+	assert.NotNil(t, be.Field("Level"))
+	assert.Equal(t, "-1", be.Field("address.zip").Value)
+	// everything else is still bound
+	assert.Equal(t, "Jo", target.Name)
+	assert.Zero(t, target.Timeout)
+}
+
+func Test_BindSliceIndexes(t *testing.T) {
+	var target bindTarget
+	err := Bind(url.Values{
+		"items.7.street":          {"Second"},
+		"items.2.street":          {"First"},
+		"items.2000000000.street": {"Huge"},
+	}, &target)
+	var be *BindError
+	assert.True(t, errors.As(err, &be))
+	assert.Len(t, be.Fields, 1)
+	assert.NotNil(t, be.Field("items.2000000000"))
+	// the items are kept in order without the gaps
+	assert.Equal(t, []bindAddress{{Street: "First"}, {Street: "Second"}}, target.Items)
+}
+
+func Test_BindRequest(t *testing.T) {
+	type input struct {
+		Id    int
+		Name  string
+		Count int
+	}
+	var v input
+	r := httptest.NewRequest(http.MethodPost, "/?id=7", strings.NewReader(`{"Name":"json","Count":2}`))
+	r.Header.Set("Content-Type", "application/json")
+	assert.NoError(t, BindRequest(r, &v))
+	assert.Equal(t, input{Id: 7, Name: "json", Count: 2}, v)
+
+	v = input{}
+	r = httptest.NewRequest(http.MethodPost, "/?id=7&name=query", strings.NewReader("name=form&count=3"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.NoError(t, BindRequest(r, &v))
+	assert.Equal(t, input{Id: 7, Name: "form", Count: 3}, v)
+
+	var upload struct {
+		Name string
+		File *multipart.FileHeader
+	}
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	mw.WriteField("name", "upload")
+	fw, _ := mw.CreateFormFile("file", "data.txt")
+	fw.Write([]byte("content"))
+	mw.Close()
+	r = httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	assert.NoError(t, BindRequest(r, &upload))
+	assert.Equal(t, "upload", upload.Name)
+	if assert.NotNil(t, upload.File) {
+		assert.Equal(t, "data.txt", upload.File.Filename)
+	}
+}
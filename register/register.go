@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/finite8/gooey/pkg/htmlwriter"
 	logrus "github.com/sirupsen/logrus"
@@ -16,14 +18,7 @@ import (
 
 // this will allow packages to register themselves with this component. Their UI's will be added into the stack
 func init() {
-	globalregister = &webregister{
-		registered:     map[string]*registeredPageInfo{},
-		queued:         map[string]*registeredPageInfo{},
-		pageRegister:   map[Page]*registeredPageInfo{},
-		fileSystems:    map[string]http.FileSystem{},
-		ctx:            context.Background(),
-		customHandlers: map[string]Page{},
-	}
+	globalregister = NewSite()
 	logger = logrus.New()
 
 }
@@ -38,12 +33,15 @@ const (
 )
 
 var (
-	globalregister *webregister
+	globalregister *Site
 	logger         *logrus.Logger
 	DefaultLayout  PageLayout
 )
 
-type webregister struct {
+// Site is a self contained GOOEY user interface: a page hierarchy, a layout and the file systems that support it.
+// Multiple sites can live in the same process without sharing any state. The package level functions (RegisterPage, Compile etc.)
+// all operate on the default site.
+type Site struct {
 	root                *registeredPageInfo
 	registered          map[string]*registeredPageInfo
 	queued              map[string]*registeredPageInfo
@@ -55,21 +53,67 @@ type webregister struct {
 	ctx                 context.Context
 	globalPreprocessors []*pagePreprocessor
 	customHandlers      map[string]Page
-	CorePages           CoreSystemPages
+	corePages           CoreSystemPages
+	compiled            bool
+	compileOptions      compileOptions
+	routes              *router
+	tree                atomic.Pointer[siteTree]
+	sequence            uint64
+	prefix              string
+	proxyPolicy         *ProxyPolicy
+	authorizers         []PageAuthorizer
+	authenticators      []Authenticator
+	sessions            *sessionManager
+	devMode             bool
+	securityHeaders     SecurityHeaders
+	appCache            SharedCache
+	sessionCaches       *syncCache
+	sessionCacheOptions CacheOptions
+	name                string
+	renderMode          RenderMode
+	// problems found as pages were registered. These are reported when the site is compiled
+	registrationProblems []CompileProblem
+}
+
+// NewSite creates an empty site. Pages, layouts and file systems need to be registered against it before it is compiled.
+func NewSite() *Site {
+	s := &Site{
+		registered:          map[string]*registeredPageInfo{},
+		queued:              map[string]*registeredPageInfo{},
+		pageRegister:        map[Page]*registeredPageInfo{},
+		fileSystems:         map[string]http.FileSystem{},
+		ctx:                 context.Background(),
+		customHandlers:      map[string]Page{},
+		sessions:            newSessionManager(SessionOptions{}),
+		appCache:            newSyncCache(defaultAppCacheOptions),
+		sessionCacheOptions: defaultSessionCacheOptions,
+		securityHeaders:     DefaultSecurityHeaders(),
+	}
+	s.sessionCaches = newSessionCaches(s.sessions.opts)
+	return s
+}
+
+// DefaultSite returns the site that the package level functions operate on
+func DefaultSite() *Site {
+	return globalregister
 }
 
 type CoreSystemPages struct {
 	// Error page handles
 	ErrorPage Page
+	// Rendered when a request is refused access to a page
+	ForbiddenPage Page
+	// Rendered when there is no page at the path that was requested. If not set, the ErrorPage is used.
+	NotFoundPage Page
 }
 
 type GOOEYHandlerFunc func(w http.ResponseWriter, r *http.Request, page Page)
 
-func (wr *webregister) FindPage(page Page) *registeredPageInfo {
-	return wr.pageRegister[page]
+func (wr *Site) FindPage(page Page) *registeredPageInfo {
+	return wr.currentTree().pages[page]
 }
 
-func (wr *webregister) RegisterPrivateSubPage(id string, page Page) {
+func (wr *Site) RegisterPrivateSubPage(id string, page Page) {
 
 }
 
@@ -78,10 +122,19 @@ type registeredPageInfo struct {
 	resolvedParent *registeredPageInfo
 	path           string
 	id             string
-	page           Page
-	children       map[string]*registeredPageInfo
-	private        bool // if private, the register will not report this page as part of any kind of menu or lookup request
-	preprocessors  []*pagePreprocessor
+	// the part of the path that belongs to this page
+	segment       string
+	page          Page
+	children      map[string]*registeredPageInfo
+	private       bool // if private, the register will not report this page as part of any kind of menu or lookup request
+	preprocessors []*pagePreprocessor
+	authorizers   []PageAuthorizer
+	nav           navOptions
+	handlerAdded  bool
+	// the order the page was registered in
+	seq uint64
+	// if true, requests that change something do not need a CSRF token
+	csrfExempt bool
 }
 
 type pagePreprocessor struct {
@@ -97,179 +150,286 @@ type PagePreprocessResult struct {
 	// If set, the returned will be used as the result. Actual outcome depends on the returned entity.
 	// - error / string: The default 500 renderer will be used to render the results
 	// - page: The page will be rendered instead.
+	// - Redirect: The browser will be redirected.
 	// - any other type: Will be treated as an unexpected error. The result is not rendered.
 	Result interface{}
 }
 
-func precompileCheck() {
-	if globalregister.root == nil {
+func (s *Site) precompileCheck() {
+	if s.root == nil {
 		logger.Warn("msg", "No Rootpage was provided. Using default Root page")
-		RegisterPage(nil, RootPageId, &defaultPage{})
+		s.RegisterPage(nil, RootPageId, &defaultPage{})
 	}
-	if globalregister.layout == nil {
+	if s.layout == nil {
 		logger.Warn("msg", "No Layout was provided. Using default layout")
-		SetLayout(DefaultLayout)
-	}
-}
-
-// Compile the pages into the required hierachy
-func Compile() error {
-	precompileCheck()
-
-	globalregister.mux.Lock()
-	defer globalregister.mux.Unlock()
-	// we are gonna be crude here. We will simply keep looping through the queued items, assigning what we can as we go through. If after a loop there are no additional queued items, we are done
-	for {
-		itemsCompiled := 0
-		for k, v_iter := range globalregister.queued {
-			log := logrus.WithField("Id", v_iter.id).WithField("Name", v_iter.page.Name())
-			v := v_iter
-			var foundParent *registeredPageInfo
-			if v.parentid != nil {
-				switch tv := v.parentid.(type) {
-				case string:
-					parentId := tv
-					foundParent = globalregister.registered[parentId]
-				case Page:
-					//var ok bool
-					foundParent = globalregister.pageRegister[tv]
-
-				}
-			}
-
-			if foundParent != nil {
-				foundParent.children[v.id] = v
-				v.resolvedParent = foundParent
-				log.WithField("ParentId", foundParent.id).Info("Page hierachy established")
-			}
-			globalregister.registered[v.id] = v
-			delete(globalregister.queued, k)
-			itemsCompiled++
+		s.SetLayout(DefaultLayout)
+	}
+}
 
-		}
-		if itemsCompiled == 0 {
-			break
+// RegisterHandlers adds the default site into the http.DefaultServeMux
+func RegisterHandlers() {
+	http.Handle("/", globalregister.Handler())
+}
+
+// SetPathPrefix mounts the site beneath the given path (i.e: "/admin") rather than the root of the host. All urls built by the site
+// will include the prefix. This must be set before the site handler is serving requests.
+func (s *Site) SetPathPrefix(prefix string) {
+	prefix = "/" + strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "/" {
+		prefix = ""
+	}
+	s.prefix = prefix
+}
+
+// PathPrefix returns the path the site has been mounted beneath. Empty if the site is at the root of the host.
+func (s *Site) PathPrefix() string {
+	return s.prefix
+}
+
+// Handler returns the http.Handler that will serve the site. If the site has not yet been compiled, it will be compiled first.
+// When a path prefix has been set, the handler can be mounted in any router (http.ServeMux, chi, gorilla etc) at that prefix. It will
+// accept requests with or without the prefix already stripped.
+func (s *Site) Handler() http.Handler {
+	if !s.compiled {
+		if err := s.Compile(); err != nil {
+			logger.WithError(err).Error("site has problems: some pages will not be reachable")
 		}
 	}
-	return nil
+	return http.HandlerFunc(s.serveHTTP)
 }
 
-func RegisterHandlers() {
-	for k, fs := range globalregister.fileSystems {
-		http.Handle("/"+k+"/", http.FileServer(fs))
+type requestKey string
+
+const (
+	originalUrlKey = requestKey("originalUrl")
+)
+
+func (s *Site) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.prefix != "" {
+		if r.URL.Path == s.prefix {
+			// make sure relative links resolve beneath the prefix
+			u := *r.URL
+			u.Path = s.resolveOrigin(r).prefix + s.prefix + "/"
+			u.RawPath = ""
+			s.writeSecurityHeaders(w, s.resolveOrigin(r), "")
+			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+			return
+		}
+		if p := strings.TrimPrefix(r.URL.Path, s.prefix); len(p) < len(r.URL.Path) && strings.HasPrefix(p, "/") {
+			r2 := r.Clone(context.WithValue(r.Context(), originalUrlKey, r.URL))
+			r2.URL.Path = p
+			r2.URL.RawPath = ""
+			r = r2
+		}
+	}
+	tree := s.currentTree()
+	if segs := splitPath(r.URL.Path); len(segs) > 0 {
+		if fs, ok := tree.routes.files[segs[0]]; ok {
+			s.writeSecurityHeaders(w, s.resolveOrigin(r), "")
+			fs.ServeHTTP(w, r)
+			return
+		}
 	}
-	rootPage := globalregister.root
+	var page Page
+	info, params := tree.routes.route(r)
+	if info != nil {
+		page = info.page
+	}
+	s.globalHandler(w, r, tree, page, params)
+}
 
-	http.HandleFunc("/", func(rw http.ResponseWriter, r *http.Request) {
-		globalregister.globalHandler(rw, r, rootPage.page)
-	})
+func (s *Site) buildRoutes() (*router, []CompileProblem) {
+	routes := &router{
+		tree:  newRouteNode(),
+		files: map[string]http.Handler{},
+	}
+	for k, fs := range s.fileSystems {
+		routes.files[k] = http.FileServer(fs)
+	}
+	rootPage := s.root
 	rootPage.path = "/"
-	for _, v := range rootPage.children {
-		registerHandlersAtPath(rootPage.page, "/", v)
+	routes.tree.info = rootPage
+	s.handlerAdded(rootPage)
+	var problems []CompileProblem
+	for _, v := range registrationOrder(rootPage.children) {
+		problems = append(problems, s.registerHandlersAtPath(routes, "/", v)...)
 	}
-
+	return routes, problems
 }
 
-func registerHandlersAtPath(parent Page, path string, pageInfo *registeredPageInfo) {
-	basePath := strings.ReplaceAll(path+"/"+pageInfo.id, "//", "/")
-	http.HandleFunc(basePath, func(rw http.ResponseWriter, r *http.Request) {
-		globalregister.globalHandler(rw, r, pageInfo.page)
-	})
+func (s *Site) registerHandlersAtPath(routes *router, path string, pageInfo *registeredPageInfo) []CompileProblem {
+	basePath := strings.ReplaceAll(path+"/"+pageInfo.segment, "//", "/")
+	if err := routes.tree.add(basePath, pageInfo); err != nil {
+		return []CompileProblem{{Kind: ProblemPathCollision, PageId: pageInfo.id, Detail: err.Error()}}
+	}
 	logger.Infof("handling path %s", basePath)
 	pageInfo.path = basePath
-	if compl, ok := pageInfo.page.(ComplexPage); ok {
-		ctx := newRegistererContext(pageInfo)
-		compl.OnHandlerAdded(ctx)
+	s.handlerAdded(pageInfo)
+	var problems []CompileProblem
+	for _, v := range registrationOrder(pageInfo.children) {
+		problems = append(problems, s.registerHandlersAtPath(routes, basePath, v)...)
+	}
+	return problems
+}
+
+// handlerAdded lets a complex page know it is being handled. This only happens once, no matter how many times the site is compiled
+func (s *Site) handlerAdded(info *registeredPageInfo) {
+	if info.handlerAdded {
+		return
 	}
-	for _, v := range pageInfo.children {
-		registerHandlersAtPath(parent, basePath, v)
+	info.handlerAdded = true
+	if compl, ok := info.page.(ComplexPage); ok {
+		compl.OnHandlerAdded(newRegistererContext(s, info))
 	}
 }
 
 func CorePages() *CoreSystemPages {
-	return &globalregister.CorePages
+	return globalregister.CorePages()
+}
+
+func (s *Site) CorePages() *CoreSystemPages {
+	return &s.corePages
 }
 
 func SetLayout(l PageLayout) {
-	if globalregister.layout != nil {
-		logger.Warnf("replacing layout of %t with new layout of %t", globalregister.layout, l)
+	globalregister.SetLayout(l)
+}
+
+// SetSiteName sets the name of the default site, which is added to the title of every page
+func SetSiteName(name string) {
+	globalregister.SetSiteName(name)
+}
+
+// SetSiteName sets the name of the site, which is added to the title of every page
+func (s *Site) SetSiteName(name string) {
+	s.name = name
+}
+
+func (s *Site) SiteName() string {
+	return s.name
+}
+
+// pageTitle is the title used when neither the layout nor the page has set one
+func (s *Site) pageTitle(page Page) string {
+	if s.name == "" {
+		return page.Name()
+	}
+	return page.Name() + " - " + s.name
+}
+
+func (s *Site) SetLayout(l PageLayout) {
+	if s.layout != nil {
+		logger.Warnf("replacing layout of %t with new layout of %t", s.layout, l)
 	} else {
 		logger.Infof("%T set as layout", l)
 	}
-	globalregister.layout = l
+	s.layout = l
 }
 
 func RegisterFileSystem(name string, fs http.FileSystem) error {
-	globalregister.mux.Lock()
-	defer globalregister.mux.Unlock()
-	if ex, ok := globalregister.fileSystems[name]; ok {
+	return globalregister.RegisterFileSystem(name, fs)
+}
+
+func (s *Site) RegisterFileSystem(name string, fs http.FileSystem) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if ex, ok := s.fileSystems[name]; ok {
 		logger.Warnf("replacing filesystem %s of %T with new filesystem of %T", ex, ex, fs)
 	} else {
 		logger.Infof("%T set as filesystem '%s'", fs, name)
 	}
-	globalregister.fileSystems[name] = fs
+	s.fileSystems[name] = fs
 	return nil
 }
 
-type PageOption interface {
-}
-
 // RegisterPage adds a renderable page into the system.
 // parent: either a resolvable ID or the actual page if available. If nil, it is put in a placeholder location for later referencing. parents may not exist yet at time of this being called
 func RegisterPage(parent interface{}, id string, page Page, opts ...PageOption) error {
-	globalregister.mux.Lock()
-	defer globalregister.mux.Unlock()
-	id = strings.TrimSpace(strings.ToLower(id))
+	return globalregister.RegisterPage(parent, id, page, opts...)
+}
+
+// RegisterPage adds a renderable page into the site.
+// parent: either a resolvable ID or the actual page if available. If nil, it is put in a placeholder location for later referencing. parents may not exist yet at time of this being called
+// Pages registered after the site has been compiled are placed (and served) straight away. Their parent must already be registered.
+func (s *Site) RegisterPage(parent interface{}, id string, page Page, opts ...PageOption) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	id = normalizePageId(id)
 	pageInfo := &registeredPageInfo{
 		parentid: parent,
 		id:       id,
+		segment:  id,
 		page:     page,
 		children: make(map[string]*registeredPageInfo),
+		seq:      s.nextSequence(),
+	}
+	for _, o := range opts {
+		o.apply(pageInfo)
 	}
 	log := logrus.WithField("Id", id)
-	if id == RootPageId {
-		if globalregister.root != nil {
-			// but the root has already been defined. Lets die
-			err := errors.New("invalid root page registration: root has already been registered")
-			log.Fatal(err)
+	if _, exists := s.queued[id]; exists || s.registered[id] != nil {
+		err := fmt.Errorf("invalid page registration: a page with the id %s has already been registered", id)
+		s.registrationProblems = append(s.registrationProblems, CompileProblem{Kind: ProblemDuplicateId, PageId: id, Detail: err.Error()})
+		log.Error(err)
+		return err
+	}
+	if pageInfo.parentid != nil {
+		// check to make sure it is a valid type
+		switch pageInfo.parentid.(type) {
+		case string:
+		case Page:
+		default:
+			err := fmt.Errorf("invalid page registration: parent type %T not allowed", pageInfo.parentid)
+			s.registrationProblems = append(s.registrationProblems, CompileProblem{Kind: ProblemUnknownParent, PageId: id, Detail: err.Error()})
+			log.Error(err)
+			return err
 		}
+	}
+	if id == RootPageId {
 		// we have a root page being registered
-		globalregister.root = pageInfo
-		globalregister.registered[RootPageId] = pageInfo
+		s.root = pageInfo
+		s.registered[RootPageId] = pageInfo
 		log.Info("Root page registered")
 	} else {
 		// this is not a root page. Coolies.
 		// we are not going to try and shuffle and organize things yet. We will just queue it all up first.
-		globalregister.queued[id] = pageInfo
-		if pageInfo.parentid != nil {
-			// check to make sure it is a valid type
-			switch pageInfo.parentid.(type) {
-			case string:
-			case Page:
-			default:
-				err := fmt.Errorf("invalid page registration: parent type %t not allowed", pageInfo.parentid)
-				log.Fatal(err)
-			}
-		}
+		s.queued[id] = pageInfo
 		log.Info("Page registered")
 	}
-	globalregister.pageRegister[page] = pageInfo
+	s.pageRegister[page] = pageInfo
+	if s.compiled {
+		return s.placePage(pageInfo)
+	}
 	return nil
 
 }
 
-func (wr *webregister) getSiteStructure() PageStructure {
-	return createpageStructureData(globalregister.root.page)
+func (s *Site) nextSequence() uint64 {
+	s.sequence++
+	return s.sequence
+}
+
+func (wr *Site) getSiteStructure(ctx PageContext) PageStructure {
+	return createpageStructureData(wr, ctx, wr.treeFor(ctx).root.page)
 }
 
 type pageStructureData struct {
-	page   Page
-	weight int
+	site *Site
+	ctx  PageContext
+	page Page
+	info *registeredPageInfo
 }
 
-func createpageStructureData(p Page) *pageStructureData {
+func createpageStructureData(site *Site, ctx PageContext, p Page) *pageStructureData {
+	info := site.treeFor(ctx).pages[p]
+	if info == nil {
+		info = &registeredPageInfo{page: p}
+	}
 	return &pageStructureData{
+		site: site,
+		ctx:  ctx,
 		page: p,
+		info: info,
 	}
 }
 
@@ -278,120 +438,188 @@ func (psd *pageStructureData) Page() Page {
 }
 
 func (psd *pageStructureData) Weight() int {
-	return psd.weight
+	return psd.info.nav.weight
 }
 func (psd *pageStructureData) Title() string {
-	return psd.page.Name()
+	title := psd.info.nav.title
+	if title == "" {
+		title = psd.page.Name()
+	}
+	return replaceParams(title, psd.ctx.PathParams())
+}
+func (psd *pageStructureData) Icon() string {
+	return psd.info.nav.icon
+}
+func (psd *pageStructureData) Description() string {
+	return psd.info.nav.description
 }
+func (psd *pageStructureData) NavGroup() string {
+	return psd.info.nav.group
+}
+func (psd *pageStructureData) Url() *url.URL {
+	return psd.ctx.GetPageUrl(psd.page)
+}
+
+// Children returns the pages beneath this one that should be shown in menus, in the order they should be shown.
 func (psd *pageStructureData) Children() []PageStructure {
-	inf := globalregister.FindPage(psd.page)
-	if inf != nil {
-		var retArr []PageStructure
-		for _, c := range inf.children {
-			retArr = append(retArr, createpageStructureData(c.page))
+	var retArr PageStructureCollection
+	for _, c := range psd.info.children {
+		if c.private || c.nav.hidden {
+			continue
+		}
+		// pages that need parameters the current request does not have cannot be linked to
+		if !canExpandPath(c.path, psd.ctx.PathParams()) {
+			continue
 		}
-		return retArr
+		// pages the current user cannot open are not shown
+		if !psd.site.authorize(c.page, psd.ctx).Authorized() {
+			continue
+		}
+		retArr = append(retArr, createpageStructureData(psd.site, psd.ctx, c.page))
 	}
-	return nil
+	sort.Sort(retArr)
+	return retArr
+}
+
+func (wr *Site) globalHandler(w http.ResponseWriter, r *http.Request, tree *siteTree, p Page, params map[string]string) {
+	ctx := newPageContext(wr, wr.ctx, r)
+	ctx.tree = tree
+	ctx.params = params
+	w, endSession := wr.beginSession(ctx, w, r)
+	defer endSession()
+	wr.writeSecurityHeaders(w, ctx.origin, ctx.Nonce())
+	defer func() {
+		if rec := recover(); rec != nil {
+			wr.renderError(ctx, w, r, Recovered(rec))
+		}
+	}()
+	if !wr.authenticate(ctx, w, r) {
+		return
+	}
+	if p == nil {
+		wr.renderError(ctx, w, r, HTTPErrorf(http.StatusNotFound, "%s could not be found", r.URL.Path))
+		return
+	}
+	if !wr.verifyCSRF(ctx, r, p) {
+		wr.renderError(ctx, w, r, HTTPErrorf(http.StatusForbidden, "the request could not be verified. Reload the page and try again"))
+		return
+	}
+	wr.handlePage(ctx, w, r, p)
 }
 
-func (wr *webregister) globalHandler(w http.ResponseWriter, r *http.Request, p Page) {
-	if r.Method == http.MethodGet {
-		cook, err := r.Cookie("test")
-		if err != nil {
-			cook = &http.Cookie{
-				Name:    "test",
-				Value:   "hello",
-				Expires: time.Now().Add(time.Hour),
-			}
-			http.SetCookie(w, cook)
-		} else {
-			logger.Infof("cookie found: %s", cook.Value)
+// handlePage authorizes and preprocesses the page before rendering it
+func (wr *Site) handlePage(ctx *pageContext, w http.ResponseWriter, r *http.Request, p Page) {
+	ctx.page = p
+	if res := wr.authorize(p, ctx); !res.Authorized() {
+		wr.handleAuthFailure(ctx, w, r, res)
+		return
+	}
+	page := p
+	if res := wr.preprocess(p, ctx); res != nil {
+		switch v := res.(type) {
+		case Page:
+			// the preprocessor has given us a different page to show
+			page = v
+			ctx.page = v
+		case Redirect:
+			v.redirect(ctx, w, r)
+			return
+		case *Redirect:
+			v.redirect(ctx, w, r)
+			return
+		case error:
+			wr.renderError(ctx, w, r, v)
+			return
+		case string:
+			wr.renderError(ctx, w, r, errors.New(v))
+			return
+		default:
+			logger.Errorf("preprocessor returned unexpected result of %T", res)
+			wr.renderError(ctx, w, r, errors.New("an unexpected error occurred"))
+			return
 		}
-
 	}
+	if dp, ok := page.(DataPage); ok && (r.Method == http.MethodGet || r.Method == http.MethodHead) && WantsJSON(r) {
+		wr.renderData(ctx, w, r, dp)
+		return
+	}
+	wr.renderPage(ctx, w, r, page)
+}
 
-	ctx := newPageContext(wr.ctx, r)
+// renderPage renders the page (and if required, the layout) into the response
+func (wr *Site) renderPage(ctx *pageContext, w http.ResponseWriter, r *http.Request, page Page) {
 	b := getNewBehaviour(wr.getNewMeta(ctx))
-	for _, o := range []interface{}{wr.layout, p} {
+	for _, o := range []interface{}{wr.layout, page} {
 		if bc, ok := o.(PageBehaviour); ok {
 			b = bc.QueryBehaviour(ctx, b)
 		}
 	}
-	var ppResult interface{}
-
-	// now we need to evaluate the preprocessors
-	// TODO
-	{
-		info := wr.FindPage(p)
-		isparent := false
-	PagePreprocessorEvaluate:
-		for info != nil {
-			for _, pp := range info.preprocessors {
-				if !isparent || pp.applyChildren {
-					r := pp.preprocessor(p, ctx)
-					if r.Result != nil {
-						ppResult = r.Result
-					}
-					if r.HaltPreprocessing {
-						break PagePreprocessorEvaluate
-					}
-				}
-			}
-			isparent = true
-			info = info.resolvedParent
-		}
-		for _, pp := range wr.globalPreprocessors {
-			r := pp.preprocessor(p, ctx)
-			if r.Result != nil {
-				ppResult = r.Result
-			}
-			if r.HaltPreprocessing {
-				break
-			}
-		}
-	}
-	var page Page
-	if ppResult == nil {
-		page = p
-	} else {
-		//TODO
+
+	var bw *bufferedWriter
+	if b.renderHTML && b.renderMode(wr.renderMode) == RenderBuffered {
+		// nothing is sent until the page is complete. If the page panics, the buffer is dropped and the error page takes over
+		bw = newBufferedWriter(w)
+		w = bw
 	}
 
 	if b.renderHTML {
 		// we need to render our HTML stuff
-		w.Write([]byte("<html>"))
+		if b.pageMeta.Title == "" {
+			b.pageMeta.Title = wr.pageTitle(page)
+		}
+		w.Write([]byte("<!DOCTYPE html><html>"))
 		b.pageMeta.Write(ctx, w)
 	}
 
 	if (b.renderHTML && b.renderLayout) && wr.layout != nil {
 		w.Write([]byte("<body>"))
 
-		wr.layout.Render(ctx, w, r, func(ctx PageContext, w http.ResponseWriter, r *http.Request) { page.Handler(ctx, w, r) }) // don't really care about the response at this stage
+		wr.layout.Render(ctx, w, r, func(pctx PageContext, w http.ResponseWriter, r *http.Request) {
+			// anything the page hands over to is rendered in its place, within the layout
+			wr.handleResult(ctx, w, r, wr.servePage(pctx, w, r, page), true)
+		})
+		GetPageScripts(ctx).Write(ctx, w)
 		w.Write([]byte("</body>"))
 	} else {
-		res := page.Handler(ctx, w, r)
-		switch v := res.(type) {
-		case Page:
-			// we were given a page as a result. That means that the handler wants us to load a different page in response.
-			wp := &pageWrapper{
-				wrapedPage: v,
-			}
-			if b.renderHTML {
-				// if the HTML has already been rendered, we need to make sure it does not render again.
-				wp.behaviourRewrite = func(b Behaviour) Behaviour {
-					b.renderHTML = false
-					return b
-				}
-			}
-			wr.globalHandler(w, r, wp)
+		var res interface{}
+		if b.renderHTML {
+			res = wr.servePage(ctx, w, r, page)
+		} else if v, err := callHandler(ctx, w, r, page); err != nil {
+			// the page is responsible for the whole response, so the error page has to be as well
+			wr.renderError(ctx, w, r, err)
+		} else {
+			res = v
 		}
+		wr.handleResult(ctx, w, r, res, b.renderHTML)
 	}
 
 	if b.renderHTML {
 		// we need to render our HTML stuff
+		GetPageScripts(ctx).Write(ctx, w)
 		w.Write([]byte("</html>"))
 	}
+	if bw != nil {
+		bw.commit(true)
+	}
+}
+
+// handleResult deals with what the handler of a page returned. A page means the handler wants a different page to be shown in response.
+func (wr *Site) handleResult(ctx *pageContext, w http.ResponseWriter, r *http.Request, res interface{}, htmlRendered bool) {
+	v, ok := res.(Page)
+	if !ok {
+		return
+	}
+	wp := &pageWrapper{
+		wrapedPage: v,
+	}
+	if htmlRendered {
+		// if the HTML has already been rendered, we need to make sure it does not render again.
+		wp.behaviourRewrite = func(b Behaviour) Behaviour {
+			b.renderHTML = false
+			return b
+		}
+	}
+	wr.handlePage(ctx, w, r, wp)
 }
 
 type pageWrapper struct {
@@ -417,8 +645,10 @@ type PageOutput interface {
 	GetHtml() htmlwriter.HtmlElement
 }
 
-func (wr *webregister) getNewMeta(ctx PageContext) *PageHead {
-	return &PageHead{}
+func (wr *Site) getNewMeta(ctx PageContext) *PageHead {
+	m := &PageHead{}
+	m.AddMeta(map[string]interface{}{"charset": "utf-8"})
+	return m
 }
 
 type Behaviour struct {
@@ -427,6 +657,8 @@ type Behaviour struct {
 	// if the page is a placeholder, it cannot
 	isPlaceholder bool
 	pageMeta      *PageHead
+	// nil uses the render mode of the site
+	mode *RenderMode
 }
 
 func getNewBehaviour(meta *PageHead) Behaviour {
@@ -448,6 +680,19 @@ func (b Behaviour) WithRenderHTML(v bool) Behaviour {
 	return b
 }
 
+// WithRenderMode overrides the render mode of the site for the page
+func (b Behaviour) WithRenderMode(m RenderMode) Behaviour {
+	b.mode = &m
+	return b
+}
+
+func (b Behaviour) renderMode(siteMode RenderMode) RenderMode {
+	if b.mode != nil {
+		return *b.mode
+	}
+	return siteMode
+}
+
 func (b Behaviour) WithMetaHandling(f func(m *PageHead) *PageHead) Behaviour {
 	b.pageMeta = f(b.pageMeta)
 	return b
@@ -0,0 +1,129 @@
+package register
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPage struct {
+	name string
+}
+
+func (tp *testPage) Name() string {
+	return tp.name
+}
+
+func (tp *testPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	fmt.Fprintf(w, "page:%s", tp.name)
+	return nil
+}
+
+func getBody(t *testing.T, h http.Handler, path string) (int, string) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	data, err := io.ReadAll(rec.Result().Body)
+	assert.NoError(t, err)
+	return rec.Code, string(data)
+}
+
+func Test_SitesAreIndependent(t *testing.T) {
+	first := NewSite()
+	first.RegisterPage(nil, RootPageId, &testPage{name: "firstroot"})
+	first.RegisterPage(RootPageId, "child", &testPage{name: "firstchild"})
+
+	second := NewSite()
+	second.RegisterPage(nil, RootPageId, &testPage{name: "secondroot"})
+	second.RegisterPage(RootPageId, "other", &testPage{name: "secondother"})
+
+	assert.NoError(t, first.Compile())
+	assert.NoError(t, second.Compile())
+
+	_, body := getBody(t, first.Handler(), "/child")
+	assert.Contains(t, body, "page:firstchild")
+	_, body = getBody(t, second.Handler(), "/other")
+	assert.Contains(t, body, "page:secondother")
+
+	// pages registered on one site must not be visible on the other
+	code, _ := getBody(t, first.Handler(), "/other")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getBody(t, second.Handler(), "/child")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Nil(t, globalregister.FindPage(first.root.page))
+}
+
+type linkPage struct {
+	testPage
+	target Page
+}
+
+func (lp *linkPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	fmt.Fprintf(w, "link:%s", ctx.GetPageUrl(lp.target).Path)
+	return nil
+}
+
+func Test_SiteMountedWithPrefix(t *testing.T) {
+	site := NewSite()
+	child := &testPage{name: "child"}
+	site.RegisterPage(nil, RootPageId, &linkPage{testPage: testPage{name: "root"}, target: child})
+	site.RegisterPage(RootPageId, "child", child)
+	site.SetPathPrefix("admin/")
+	assert.Equal(t, "/admin", site.PathPrefix())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	mux.Handle("/admin/", site.Handler())
+
+	_, body := getBody(t, mux, "/healthz")
+	assert.Equal(t, "ok", body)
+	_, body = getBody(t, mux, "/admin/")
+	assert.Contains(t, body, "link:/admin/child")
+	_, body = getBody(t, mux, "/admin/child")
+	assert.Contains(t, body, "page:child")
+
+	// routers that strip the prefix themselves are also supported
+	stripped := http.StripPrefix("/admin", site.Handler())
+	_, body = getBody(t, stripped, "/admin/child")
+	assert.Contains(t, body, "page:child")
+
+	code, _ := getBody(t, site.Handler(), "/admin")
+	assert.Equal(t, http.StatusMovedPermanently, code)
+}
+
+func Test_PageOptions(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "b", &testPage{name: "b"}, WithNavGroup("Admin"), WithIcon("bi-gear"))
+	site.RegisterPage(RootPageId, "a", &testPage{name: "a"}, WithNavGroup("Admin"))
+	site.RegisterPage(RootPageId, "z", &testPage{name: "z"}, WithWeight(-1), WithTitle("Home"), WithDescription("Start here"))
+	site.RegisterPage(RootPageId, "hidden", &testPage{name: "hidden"}, Hidden())
+	h := site.Handler()
+
+	ctx := newPageContext(site, site.ctx, httptest.NewRequest(http.MethodGet, "/", nil))
+	children := ctx.SiteRoot().Children()
+	var titles []string
+	for _, c := range children {
+		titles = append(titles, c.Title())
+	}
+	assert.Equal(t, []string{"Home", "a", "b"}, titles)
+	assert.Equal(t, "Start here", children[0].Description())
+	assert.Equal(t, "bi-gear", children[2].Icon())
+	assert.Equal(t, "http://example.com/a", children[1].Url().String())
+
+	groups := GroupPages(children)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "", groups[0].Name)
+	assert.Equal(t, "Admin", groups[1].Name)
+	assert.Len(t, groups[1].Pages, 2)
+
+	// hidden pages can still be opened
+	code, body := getBody(t, h, "/hidden")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "page:hidden")
+}
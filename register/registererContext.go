@@ -9,11 +9,13 @@ type Registerer interface {
 }
 
 type registererContext struct {
+	site        *Site
 	currentPage *registeredPageInfo
 }
 
-func newRegistererContext(currentPage *registeredPageInfo) Registerer {
+func newRegistererContext(site *Site, currentPage *registeredPageInfo) Registerer {
 	return &registererContext{
+		site:        site,
 		currentPage: currentPage,
 	}
 }
@@ -23,15 +25,19 @@ func (rc *registererContext) ThisPage() Page {
 }
 
 func (rc *registererContext) RegisterPrivateSubPage(id string, newPage Page) {
-	id = rc.currentPage.id + "-" + id
+	segment := normalizePageId(id)
+	id = rc.currentPage.id + "-" + segment
 	info := &registeredPageInfo{
-		parentid: rc.currentPage.id,
-		id:       id,
-		page:     newPage,
-		private:  true,
+		parentid:       rc.currentPage.id,
+		resolvedParent: rc.currentPage,
+		id:             id,
+		segment:        segment,
+		page:           newPage,
+		children:       make(map[string]*registeredPageInfo),
+		private:        true,
+		seq:            rc.site.nextSequence(),
 	}
 	rc.currentPage.children[id] = info
-	globalregister.queued[id] = info
-	globalregister.registered[id] = info
-	globalregister.pageRegister[newPage] = info
+	rc.site.registered[id] = info
+	rc.site.pageRegister[newPage] = info
 }
@@ -0,0 +1,99 @@
+package register
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// RenderMode controls when the response of a page is sent to the client
+type RenderMode int
+
+const (
+	// RenderStreaming sends the document as it is written, so long pages start showing before they are complete. Once the first byte has
+	// been written, the status can no longer be changed. This is the default.
+	RenderStreaming RenderMode = iota
+	// RenderBuffered collects the whole document before anything is sent, so the page, its components and the error pages can still
+	// change the status code, headers and cookies while rendering.
+	RenderBuffered
+)
+
+// SetRenderMode sets how the pages of the default site are rendered unless a page asks otherwise
+func SetRenderMode(m RenderMode) {
+	globalregister.SetRenderMode(m)
+}
+
+// SetRenderMode sets how pages are rendered unless a page asks otherwise through its Behaviour
+func (s *Site) SetRenderMode(m RenderMode) {
+	s.renderMode = m
+}
+
+// bufferedWriter holds on to the response until it is flushed. Headers are written straight to the underlying writer, as they are not
+// sent until the status is.
+type bufferedWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     bytes.Buffer
+	flushed bool
+}
+
+func newBufferedWriter(w http.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w}
+}
+
+// WriteHeader sets the status of the response. Unlike a normal writer, the last status written is the one that is sent.
+func (bw *bufferedWriter) WriteHeader(statusCode int) {
+	if bw.flushed {
+		// too late, let the underlying writer complain about it
+		bw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	bw.status = statusCode
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.flushed {
+		return bw.ResponseWriter.Write(b)
+	}
+	return bw.buf.Write(b)
+}
+
+// Flush sends everything written so far. After this, the writer streams the rest of the response.
+func (bw *bufferedWriter) Flush() {
+	bw.commit(false)
+	if f, ok := bw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// commit sends the status and the buffered document. The document is dropped if the response became a redirect part way through.
+func (bw *bufferedWriter) commit(complete bool) {
+	if bw.flushed {
+		return
+	}
+	bw.flushed = true
+	status := bw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if isRedirect(status) && bw.Header().Get("Location") != "" {
+		bw.Header().Del("Content-Type")
+		bw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if complete && bw.Header().Get("Content-Length") == "" {
+		bw.Header().Set("Content-Length", strconv.Itoa(bw.buf.Len()))
+	}
+	if bw.status != 0 || !headersSent(bw.ResponseWriter) {
+		// the error pages set the status before the page is rendered
+		bw.ResponseWriter.WriteHeader(status)
+	}
+	if _, err := bw.ResponseWriter.Write(bw.buf.Bytes()); err != nil {
+		logger.WithError(err).Debug("failed to write response")
+	}
+	bw.buf.Reset()
+}
+
+func isRedirect(status int) bool {
+	return status >= http.StatusMultipleChoices && status < http.StatusBadRequest && status != http.StatusNotModified
+}
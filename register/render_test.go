@@ -0,0 +1,71 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RenderModes(t *testing.T) {
+	site := NewSite()
+	site.SetRenderMode(RenderBuffered)
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "late", &funcPage{name: "late", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		fmt.Fprint(w, "half a page")
+		http.SetCookie(w, &http.Cookie{Name: "seen", Value: "yes"})
+		w.WriteHeader(http.StatusTeapot)
+		return nil
+	}})
+	site.RegisterPage(RootPageId, "moved", &funcPage{name: "moved", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		fmt.Fprint(w, "you should not see this")
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return nil
+	}})
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/late", nil))
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.Contains(t, rec.Body.String(), "half a page")
+	assert.Contains(t, rec.Header().Get("Set-Cookie"), "seen=yes")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moved", nil))
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/", rec.Header().Get("Location"))
+	assert.Empty(t, rec.Body.String())
+
+	// streaming sends the status as soon as the document starts
+	site.SetRenderMode(RenderStreaming)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/late", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "half a page")
+}
+
+type wrappingLayout struct{}
+
+func (wl *wrappingLayout) Render(ctx PageContext, w http.ResponseWriter, r *http.Request, pageRenderer func(ctx PageContext, w http.ResponseWriter, r *http.Request)) {
+	fmt.Fprint(w, "[layout]")
+	pageRenderer(ctx, w, r)
+	fmt.Fprint(w, "[/layout]")
+}
+
+func (wl *wrappingLayout) OnHandlerAdded(reg Registerer) {}
+
+func Test_LayoutPageResult(t *testing.T) {
+	site := NewSite()
+	site.SetLayout(&wrappingLayout{})
+	site.RegisterPage(nil, RootPageId, &funcPage{name: "root", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		return &testPage{name: "other"}
+	}})
+
+	_, body := getBody(t, site.Handler(), "/")
+	// the page handed over to is shown in place of the page, once
+	assert.Contains(t, body, "<body>[layout]page:other[/layout]")
+	assert.Equal(t, 1, strings.Count(body, "<html>"))
+}
@@ -0,0 +1,232 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Page ids (and so the segments of their paths) can be parameters:
+//   - "{name}" matches any single segment (i.e: /jobs/{jobId})
+//   - "{name...}" matches the rest of the path, and must be the last segment (i.e: /files/{path...})
+// Static segments are matched before parameters, and parameters before wildcards.
+
+type segmentKind int
+
+const (
+	staticSegment segmentKind = iota
+	paramSegment
+	wildcardSegment
+)
+
+func parseSegment(seg string) (string, segmentKind) {
+	if len(seg) < 3 || seg[0] != '{' || seg[len(seg)-1] != '}' {
+		return seg, staticSegment
+	}
+	name := seg[1 : len(seg)-1]
+	if strings.HasSuffix(name, "...") {
+		return strings.TrimSuffix(name, "..."), wildcardSegment
+	}
+	return name, paramSegment
+}
+
+// normalizePageId lowercases the id, except for the names of any parameters
+func normalizePageId(id string) string {
+	id = strings.TrimSpace(id)
+	sb := strings.Builder{}
+	inParam := false
+	for _, c := range id {
+		switch c {
+		case '{':
+			inParam = true
+		case '}':
+			inParam = false
+		}
+		if inParam {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteString(strings.ToLower(string(c)))
+		}
+	}
+	return sb.String()
+}
+
+type routeNode struct {
+	static       map[string]*routeNode
+	param        *routeNode
+	paramName    string
+	wildcard     *routeNode
+	wildcardName string
+	info         *registeredPageInfo
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		static: map[string]*routeNode{},
+	}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func (rn *routeNode) add(path string, info *registeredPageInfo) error {
+	curr := rn
+	segs := splitPath(path)
+	for ix, seg := range segs {
+		name, kind := parseSegment(seg)
+		switch kind {
+		case staticSegment:
+			next, ok := curr.static[seg]
+			if !ok {
+				next = newRouteNode()
+				curr.static[seg] = next
+			}
+			curr = next
+		case paramSegment:
+			if curr.param == nil {
+				curr.param = newRouteNode()
+				curr.paramName = name
+			} else if curr.paramName != name {
+				return fmt.Errorf("path %s conflicts with the parameter {%s}", path, curr.paramName)
+			}
+			curr = curr.param
+		case wildcardSegment:
+			if ix != len(segs)-1 {
+				return fmt.Errorf("path %s has a wildcard that is not the last segment", path)
+			}
+			if curr.wildcard == nil {
+				curr.wildcard = newRouteNode()
+				curr.wildcardName = name
+			} else if curr.wildcardName != name {
+				return fmt.Errorf("path %s conflicts with the wildcard {%s...}", path, curr.wildcardName)
+			}
+			curr = curr.wildcard
+		}
+	}
+	if curr.info != nil && curr.info != info {
+		return fmt.Errorf("path %s is already used by page %s", path, curr.info.id)
+	}
+	curr.info = info
+	return nil
+}
+
+// match finds the page for the (unescaped) path segments, filling in the parameters it resolves
+func (rn *routeNode) match(segs []string, params map[string]string) *registeredPageInfo {
+	if len(segs) == 0 {
+		return rn.info
+	}
+	if next, ok := rn.static[segs[0]]; ok {
+		if info := next.match(segs[1:], params); info != nil {
+			return info
+		}
+	}
+	if rn.param != nil && segs[0] != "" {
+		if info := rn.param.match(segs[1:], params); info != nil {
+			params[rn.paramName] = segs[0]
+			return info
+		}
+	}
+	if rn.wildcard != nil && rn.wildcard.info != nil {
+		params[rn.wildcardName] = strings.Join(segs, "/")
+		return rn.wildcard.info
+	}
+	return nil
+}
+
+// clone copies the tree, swapping the pages it routes to for their copies in infos
+func (rn *routeNode) clone(infos map[*registeredPageInfo]*registeredPageInfo) *routeNode {
+	c := &routeNode{
+		static:       make(map[string]*routeNode, len(rn.static)),
+		paramName:    rn.paramName,
+		wildcardName: rn.wildcardName,
+		info:         infos[rn.info],
+	}
+	for seg, next := range rn.static {
+		c.static[seg] = next.clone(infos)
+	}
+	if rn.param != nil {
+		c.param = rn.param.clone(infos)
+	}
+	if rn.wildcard != nil {
+		c.wildcard = rn.wildcard.clone(infos)
+	}
+	return c
+}
+
+type router struct {
+	tree  *routeNode
+	files map[string]http.Handler
+}
+
+// route finds the page that handles the request. Returns nil if no page matches
+func (rt *router) route(r *http.Request) (*registeredPageInfo, map[string]string) {
+	var segs []string
+	for _, seg := range splitPath(r.URL.EscapedPath()) {
+		if v, err := url.PathUnescape(seg); err == nil {
+			seg = v
+		}
+		segs = append(segs, seg)
+	}
+	params := map[string]string{}
+	return rt.tree.match(segs, params), params
+}
+
+// expandPath fills the parameters of a page path. Values are used in order, and any parameters without a value are taken from current.
+func expandPath(path string, current map[string]string, values []interface{}) string {
+	segs := strings.Split(path, "/")
+	ix := 0
+	for i, seg := range segs {
+		name, kind := parseSegment(seg)
+		if kind == staticSegment {
+			continue
+		}
+		var val string
+		if ix < len(values) {
+			val = fmt.Sprint(values[ix])
+			ix++
+		} else if v, ok := current[name]; ok {
+			val = v
+		} else {
+			logger.Warnf("no value was given for the parameter %s of %s", name, path)
+		}
+		if kind == wildcardSegment {
+			parts := strings.Split(val, "/")
+			for pix, p := range parts {
+				parts[pix] = url.PathEscape(p)
+			}
+			segs[i] = strings.Join(parts, "/")
+		} else {
+			segs[i] = url.PathEscape(val)
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// canExpandPath returns true if all parameters of the path have a value in current
+func canExpandPath(path string, current map[string]string) bool {
+	for _, seg := range strings.Split(path, "/") {
+		if name, kind := parseSegment(seg); kind != staticSegment {
+			if _, ok := current[name]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// replaceParams replaces any {name} in the text with the value of the parameter
+func replaceParams(text string, current map[string]string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	for k, v := range current {
+		text = strings.ReplaceAll(text, "{"+k+"}", v)
+	}
+	return text
+}
@@ -0,0 +1,84 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type paramPage struct {
+	name string
+}
+
+func (pp *paramPage) Name() string {
+	return pp.name
+}
+
+func (pp *paramPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	fmt.Fprintf(w, "page:%s params:%v", pp.name, ctx.PathParams())
+	return nil
+}
+
+func Test_PathParams(t *testing.T) {
+	site := NewSite()
+	jobs := &paramPage{name: "jobs"}
+	job := &paramPage{name: "job"}
+	logs := &paramPage{name: "logs"}
+	latest := &paramPage{name: "latest"}
+	files := &paramPage{name: "files"}
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	site.RegisterPage(RootPageId, "jobs", jobs)
+	site.RegisterPage(jobs, "{jobId}", job, WithTitle("Job {jobId}"))
+	site.RegisterPage(jobs, "latest", latest)
+	site.RegisterPage(job, "logs", logs)
+	site.RegisterPage(RootPageId, "files", files)
+	site.RegisterPage(files, "{path...}", &paramPage{name: "file"})
+	h := site.Handler()
+
+	_, body := getBody(t, h, "/jobs/42/logs")
+	assert.Contains(t, body, "page:logs params:map[jobId:42]")
+	// static segments win over parameters
+	_, body = getBody(t, h, "/jobs/latest")
+	assert.Contains(t, body, "page:latest params:map[]")
+	_, body = getBody(t, h, "/jobs/a%2Fb")
+	assert.Contains(t, body, "page:job params:map[jobId:a/b]")
+	_, body = getBody(t, h, "/files/a/b/c.txt")
+	assert.Contains(t, body, "page:file params:map[path:a/b/c.txt]")
+
+	ctx := newPageContext(site, site.ctx, httptest.NewRequest(http.MethodGet, "/jobs/42/logs", nil))
+	ctx.page = logs
+	ctx.params = map[string]string{"jobId": "42"}
+	assert.Equal(t, "http://example.com/jobs/7/logs", ctx.GetPageUrl(logs, 7).String())
+	assert.Equal(t, "http://example.com/jobs/a%2Fb", ctx.GetPageUrl(job, "a/b").String())
+	// parameters not given come from the current request
+	assert.Equal(t, "http://example.com/jobs/42", ctx.GetPageUrl(job).String())
+
+	var crumbs []string
+	for _, c := range ctx.Breadcrumbs() {
+		crumbs = append(crumbs, c.Title()+"="+c.Url().Path)
+	}
+	assert.Equal(t, []string{"root=/", "jobs=/jobs", "Job 42=/jobs/42", "logs=/jobs/42/logs"}, crumbs)
+
+	// pages that need parameters are only in the nav when the parameters are known
+	titles := func(ps []PageStructure) (ret []string) {
+		for _, p := range ps {
+			ret = append(ret, p.Title())
+		}
+		return
+	}
+	jobsStructure := createpageStructureData(site, ctx, jobs)
+	assert.Equal(t, []string{"Job 42", "latest"}, titles(jobsStructure.Children()))
+	ctx.params = map[string]string{}
+	assert.Equal(t, []string{"latest"}, titles(jobsStructure.Children()))
+}
+
+func Test_RouteConflicts(t *testing.T) {
+	tree := newRouteNode()
+	assert.NoError(t, tree.add("/a/{id}", &registeredPageInfo{id: "1"}))
+	assert.Error(t, tree.add("/a/{other}/b", &registeredPageInfo{id: "2"}))
+	assert.Error(t, tree.add("/a/{rest...}/b", &registeredPageInfo{id: "3"}))
+	assert.Error(t, tree.add("/a/{id}", &registeredPageInfo{id: "4"}))
+}
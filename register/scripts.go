@@ -0,0 +1,118 @@
+package register
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const pageScriptsKey = "GOOEY_pagescripts"
+
+// PageScripts collects the scripts and styles contributed by the layout and the components of a page while it renders. They are
+// written once, at the end of the body.
+type PageScripts struct {
+	sources  []*ScriptElement
+	styles   []*ScriptSection
+	sections []*ScriptSection
+}
+
+// ScriptSection is a named block of script (or style). Everything written to a section ends up in a single element, in the order it was
+// written.
+type ScriptSection struct {
+	Name string
+	Type string
+	data strings.Builder
+	once map[string]bool
+}
+
+func (ss *ScriptSection) Write(p []byte) (int, error) {
+	ss.WriteString(string(p))
+	return len(p), nil
+}
+
+func (ss *ScriptSection) WriteString(s string) (int, error) {
+	return ss.data.WriteString(s)
+}
+
+// Once writes the script only the first time the key is used in the section, so components that appear several times on a page can all
+// write their setup code without it being repeated.
+func (ss *ScriptSection) Once(key, s string) {
+	if ss.once == nil {
+		ss.once = map[string]bool{}
+	}
+	if !ss.once[key] {
+		ss.once[key] = true
+		ss.data.WriteString(s)
+	}
+}
+
+// GetPageScripts returns the scripts collected for the current request
+func GetPageScripts(ctx PageContext) *PageScripts {
+	if v, ok := ctx.RequestCache().GetValue(pageScriptsKey); ok {
+		return v.(*PageScripts)
+	}
+	ps := &PageScripts{}
+	ctx.RequestCache().SetValue(pageScriptsKey, ps)
+	return ps
+}
+
+// Require declares an external script the page depends on. Each source is only loaded once, no matter how many components require it.
+func (ps *PageScripts) Require(source interface{}) *ScriptElement {
+	for _, s := range ps.sources {
+		if sameSource(s.Src, source) {
+			return s
+		}
+	}
+	s := newScriptElement(source)
+	ps.sources = append(ps.sources, s)
+	return s
+}
+
+// Section returns the script section with the name, creating it if this is the first time it has been asked for
+func (ps *PageScripts) Section(name, scriptType string) *ScriptSection {
+	return findSection(&ps.sections, name, scriptType)
+}
+
+// StyleSection returns the style section with the name, creating it if this is the first time it has been asked for
+func (ps *PageScripts) StyleSection(name string) *ScriptSection {
+	return findSection(&ps.styles, name, "")
+}
+
+func findSection(sections *[]*ScriptSection, name, sectionType string) *ScriptSection {
+	for _, s := range *sections {
+		if s.Name == name && s.Type == sectionType {
+			return s
+		}
+	}
+	s := &ScriptSection{Name: name, Type: sectionType}
+	*sections = append(*sections, s)
+	return s
+}
+
+// Write writes everything that has been collected so far: the required scripts first, so the sections can use them. Anything written is
+// cleared so that it cannot be written twice.
+func (ps *PageScripts) Write(ctx PageContext, w io.Writer) {
+	sb := strings.Builder{}
+	for _, s := range ps.sources {
+		html, err := RenderPageElement(ctx, s)
+		if err != nil {
+			logger.WithError(err).Errorf("failed to render script %v", s.Src)
+			continue
+		}
+		sb.WriteString(html)
+	}
+	for _, s := range ps.styles {
+		fmt.Fprintf(&sb, "<style%s>\n%s\n</style>\n", nonceAttribute(ctx), s.data.String())
+	}
+	for _, s := range ps.sections {
+		if s.Type != "" {
+			fmt.Fprintf(&sb, "<script type=\"%s\"%s>\n", EscapeAttribute(s.Type), nonceAttribute(ctx))
+		} else {
+			fmt.Fprintf(&sb, "<script%s>\n", nonceAttribute(ctx))
+		}
+		sb.WriteString(s.data.String())
+		sb.WriteString("\n</script>\n")
+	}
+	ps.sources, ps.styles, ps.sections = nil, nil, nil
+	io.WriteString(w, sb.String())
+}
@@ -0,0 +1,85 @@
+package register
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// NoncePlaceholder is replaced with the nonce of the request in the Content-Security-Policy
+const NoncePlaceholder = "{nonce}"
+
+// SecurityHeaders are the headers the site sends with every response. Any that are empty are not sent.
+type SecurityHeaders struct {
+	// ContentSecurityPolicy can use NoncePlaceholder to allow the scripts and styles GOOEY writes, which all carry the nonce
+	ContentSecurityPolicy string
+	// FrameOptions is sent as X-Frame-Options
+	FrameOptions   string
+	ReferrerPolicy string
+	// ContentTypeOptions is sent as X-Content-Type-Options
+	ContentTypeOptions string
+	// StrictTransportSecurity is only sent when the request came in over https
+	StrictTransportSecurity string
+}
+
+// DefaultSecurityHeaders returns the headers a site sends unless it is given others. Only scripts and styles from the site itself (or
+// that carry the nonce of the request) are allowed, and pages cannot be shown in frames.
+func DefaultSecurityHeaders() SecurityHeaders {
+	return SecurityHeaders{
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-" + NoncePlaceholder + "'; style-src 'self' 'nonce-" + NoncePlaceholder +
+			"'; img-src 'self' data:; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'",
+		FrameOptions:            "DENY",
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		ContentTypeOptions:      "nosniff",
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+	}
+}
+
+// SetSecurityHeaders sets the headers the default site sends with every response
+func SetSecurityHeaders(h SecurityHeaders) {
+	globalregister.SetSecurityHeaders(h)
+}
+
+// SetSecurityHeaders sets the headers sent with every response. Start from DefaultSecurityHeaders to change only some of them.
+func (s *Site) SetSecurityHeaders(h SecurityHeaders) {
+	s.securityHeaders = h
+}
+
+// writeSecurityHeaders adds the security headers to the response. This has to happen before anything is written. Responses that are
+// not pages (i.e: files and redirects) have no nonce, so it is left out of the policy.
+func (s *Site) writeSecurityHeaders(w http.ResponseWriter, origin requestOrigin, nonce string) {
+	h := s.securityHeaders
+	set := func(name, value string) {
+		if value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	csp := h.ContentSecurityPolicy
+	if nonce == "" {
+		csp = strings.ReplaceAll(csp, " 'nonce-"+NoncePlaceholder+"'", "")
+	}
+	set("Content-Security-Policy", strings.ReplaceAll(csp, NoncePlaceholder, nonce))
+	set("X-Frame-Options", h.FrameOptions)
+	set("Referrer-Policy", h.ReferrerPolicy)
+	set("X-Content-Type-Options", h.ContentTypeOptions)
+	if origin.scheme == "https" {
+		set("Strict-Transport-Security", h.StrictTransportSecurity)
+	}
+}
+
+// nonceAttribute is the nonce attribute for inline script and style elements
+func nonceAttribute(ctx PageContext) string {
+	return ` nonce="` + EscapeAttribute(ctx.Nonce()) + `"`
+}
+
+func (pctx *pageContext) Nonce() string {
+	if pctx.nonce == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			panic(err)
+		}
+		pctx.nonce = base64.StdEncoding.EncodeToString(b)
+	}
+	return pctx.nonce
+}
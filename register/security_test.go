@@ -0,0 +1,76 @@
+package register
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SecurityHeaders(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &funcPage{name: "root", f: func(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+		ps := GetPageScripts(ctx)
+		ps.Require("/app.js")
+		ps.Section("init", "").WriteString("console.log('hi')")
+		ps.StyleSection("main").WriteString("body { margin: 0 }")
+		ps.Write(ctx, w)
+		return nil
+	}})
+	h := site.Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	csp := rec.Header().Get("Content-Security-Policy")
+	assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "strict-origin-when-cross-origin", rec.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+	assert.Empty(t, rec.Header().Get("Strict-Transport-Security"))
+	assert.NotContains(t, csp, NoncePlaceholder)
+	nonce := strings.SplitN(strings.SplitN(csp, "'nonce-", 2)[1], "'", 2)[0]
+	assert.NotEmpty(t, nonce)
+	body := rec.Body.String()
+	assert.Equal(t, 3, strings.Count(body, `nonce="`+nonce+`"`), body)
+
+	// every request gets its own nonce, and https adds HSTS
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://example.com/", nil))
+	assert.NotContains(t, rec.Header().Get("Content-Security-Policy"), nonce)
+	assert.Equal(t, "max-age=31536000; includeSubDomains", rec.Header().Get("Strict-Transport-Security"))
+
+	headers := DefaultSecurityHeaders()
+	headers.FrameOptions = ""
+	headers.ContentSecurityPolicy = "default-src 'self'"
+	site.SetSecurityHeaders(headers)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Empty(t, rec.Header().Get("X-Frame-Options"))
+	assert.Equal(t, "default-src 'self'", rec.Header().Get("Content-Security-Policy"))
+}
+
+func Test_SecurityHeadersOutsidePages(t *testing.T) {
+	dir := t.TempDir()
+	// the file server is given the whole path, name of the file system included
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "static"), 0o700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "static", "app.js"), []byte("run()"), 0o600))
+	site := NewSite()
+	site.SetPathPrefix("/ops")
+	site.RegisterPage(nil, RootPageId, &testPage{name: "root"})
+	assert.NoError(t, site.RegisterFileSystem("static", http.Dir(dir)))
+	h := site.Handler()
+
+	for _, target := range []string{"/ops/static/app.js", "https://example.com/ops"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Less(t, rec.Code, 400, target)
+		csp := rec.Header().Get("Content-Security-Policy")
+		assert.Contains(t, csp, "default-src 'self'", target)
+		assert.NotContains(t, csp, "nonce", target)
+		assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"), target)
+		assert.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"), target)
+	}
+}
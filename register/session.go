@@ -0,0 +1,456 @@
+package register
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionData is the persisted state of a session.
+type SessionData struct {
+	Id       string
+	User     *User
+	Values   map[string]string
+	Created  time.Time
+	LastSeen time.Time
+}
+
+func (sd *SessionData) clone() *SessionData {
+	c := *sd
+	c.Values = make(map[string]string, len(sd.Values))
+	for k, v := range sd.Values {
+		c.Values[k] = v
+	}
+	if sd.User != nil {
+		u := *sd.User
+		c.User = &u
+	}
+	return &c
+}
+
+// Session is the state that follows a single browser between requests. Changes are persisted into the SessionStore once the request completes.
+type Session struct {
+	mux       sync.Mutex
+	data      *SessionData
+	isNew     bool
+	dirty     bool
+	renewed   bool
+	destroyed bool
+}
+
+func newSession() *Session {
+	now := time.Now()
+	return &Session{
+		data: &SessionData{
+			Id:       newSessionId(),
+			Values:   map[string]string{},
+			Created:  now,
+			LastSeen: now,
+		},
+		isNew: true,
+	}
+}
+
+func newSessionId() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Id returns the unique id of the session
+func (s *Session) Id() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.data.Id
+}
+
+// Created returns when the session was started
+func (s *Session) Created() time.Time {
+	return s.data.Created
+}
+
+// User returns the user that has signed in to this session. Nil if the session is anonymous
+func (s *Session) User() *User {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.data.User
+}
+
+// SetUser signs the given user into the session. As the privilege of the session is changing, the session is given a new id.
+// Setting the user to nil signs the user out but keeps the rest of the session.
+func (s *Session) SetUser(u *User) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data.User = u
+	s.renew()
+}
+
+// Get returns a value stored in the session
+func (s *Session) Get(key string) (string, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	v, ok := s.data.Values[key]
+	return v, ok
+}
+
+// Set stores a value in the session
+func (s *Session) Set(key, value string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.data.Values[key] = value
+	s.dirty = true
+}
+
+// Remove deletes a value from the session
+func (s *Session) Remove(key string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.dirty = true
+	}
+}
+
+// Destroy ends the session (i.e: logout). The session is removed from the store and the cookie is cleared.
+// Any values set after this will go into a new session.
+func (s *Session) Destroy() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.destroyed = true
+	s.data.User = nil
+	s.data.Values = map[string]string{}
+	s.renew()
+}
+
+func (s *Session) isEmpty() bool {
+	return len(s.data.Values) == 0 && s.data.User == nil
+}
+
+// persist makes sure the session is saved (and the browser given its cookie), even if nothing has been stored in it
+func (s *Session) persist() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.dirty = true
+}
+
+func (s *Session) renew() {
+	if !s.isNew && !s.renewed {
+		s.renewed = true
+	}
+	s.dirty = true
+}
+
+// SessionStore persists sessions between requests
+type SessionStore interface {
+	// Load returns the session with the given id. If it does not exist, nil is returned with no error.
+	Load(id string) (*SessionData, error)
+	Save(data *SessionData) error
+	Delete(id string) error
+	// Purge removes all sessions that the given function reports as expired
+	Purge(expired func(*SessionData) bool) error
+}
+
+// SessionOptions configure how a site manages sessions
+type SessionOptions struct {
+	// The name of the cookie that holds the session id (default "GOOEY_session")
+	CookieName string
+	// The key used to sign session cookies. If not set, a random key is generated and sessions will not survive a restart.
+	Secret []byte
+	// Where sessions are kept (default is in memory)
+	Store SessionStore
+	// How long a session can go unused before it expires (default 1 hour)
+	IdleTimeout time.Duration
+	// How long a session can exist regardless of use (default 24 hours)
+	AbsoluteTimeout time.Duration
+	// The SameSite mode of the session cookie (default Lax, which keeps the cookie off requests from other sites that change things)
+	SameSite http.SameSite
+	// If true, the cookie is always marked as secure. Otherwise it is only when the request came in over https
+	SecureCookie bool
+}
+
+const (
+	defaultSessionCookie = "GOOEY_session"
+	// how often LastSeen needs to be updated in the store. Saves the store being written to on every request.
+	sessionTouchInterval = time.Minute
+	sessionPurgeInterval = 10 * time.Minute
+)
+
+type sessionManager struct {
+	opts      SessionOptions
+	mux       sync.Mutex
+	lastPurge time.Time
+}
+
+func newSessionManager(opts SessionOptions) *sessionManager {
+	if opts.CookieName == "" {
+		opts.CookieName = defaultSessionCookie
+	}
+	if len(opts.Secret) == 0 {
+		opts.Secret = make([]byte, 32)
+		if _, err := rand.Read(opts.Secret); err != nil {
+			panic(err)
+		}
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemorySessionStore()
+	}
+	if opts.IdleTimeout == 0 {
+		opts.IdleTimeout = time.Hour
+	}
+	if opts.AbsoluteTimeout == 0 {
+		opts.AbsoluteTimeout = 24 * time.Hour
+	}
+	if opts.SameSite == 0 {
+		opts.SameSite = http.SameSiteLaxMode
+	}
+	return &sessionManager{
+		opts:      opts,
+		lastPurge: time.Now(),
+	}
+}
+
+// SetSessionOptions configures the sessions of the default site
+func SetSessionOptions(opts SessionOptions) {
+	globalregister.SetSessionOptions(opts)
+}
+
+// SetSessionOptions configures how the site manages sessions. This should be called before the site starts serving requests.
+func (s *Site) SetSessionOptions(opts SessionOptions) {
+	s.sessions = newSessionManager(opts)
+	s.sessionCaches = newSessionCaches(s.sessions.opts)
+}
+
+func (sm *sessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, sm.opts.Secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (sm *sessionManager) verify(value string) (string, bool) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	if !hmac.Equal([]byte(sm.sign(parts[0])), []byte(value)) {
+		return "", false
+	}
+	return parts[0], true
+}
+
+func (sm *sessionManager) isExpired(sd *SessionData) bool {
+	now := time.Now()
+	return now.Sub(sd.LastSeen) > sm.opts.IdleTimeout || now.Sub(sd.Created) > sm.opts.AbsoluteTimeout
+}
+
+// load finds the session for the request. A new session is created if there is not a valid one.
+func (sm *sessionManager) load(r *http.Request) *Session {
+	sm.purge()
+	cook, err := r.Cookie(sm.opts.CookieName)
+	if err != nil {
+		return newSession()
+	}
+	id, ok := sm.verify(cook.Value)
+	if !ok {
+		logger.Warn("session cookie failed verification")
+		return newSession()
+	}
+	data, err := sm.opts.Store.Load(id)
+	if err != nil {
+		logger.WithError(err).Error("failed to load session")
+		return newSession()
+	}
+	if data == nil {
+		return newSession()
+	}
+	if sm.isExpired(data) {
+		if err := sm.opts.Store.Delete(id); err != nil {
+			logger.WithError(err).Error("failed to delete expired session")
+		}
+		return newSession()
+	}
+	if data.Values == nil {
+		data.Values = map[string]string{}
+	}
+	sess := &Session{
+		data: data,
+	}
+	if time.Since(data.LastSeen) > sessionTouchInterval {
+		data.LastSeen = time.Now()
+		sess.dirty = true
+	}
+	return sess
+}
+
+func (sm *sessionManager) purge() {
+	sm.mux.Lock()
+	if time.Since(sm.lastPurge) < sessionPurgeInterval {
+		sm.mux.Unlock()
+		return
+	}
+	sm.lastPurge = time.Now()
+	sm.mux.Unlock()
+	go func() {
+		if err := sm.opts.Store.Purge(sm.isExpired); err != nil {
+			logger.WithError(err).Error("failed to purge sessions")
+		}
+	}()
+}
+
+// cookie returns the cookie that needs to be sent to the client, or nil if the client already has the right cookie. A new session is only
+// given a cookie once something has been stored in it, so browsing anonymously doesn't leave a session behind.
+func (sm *sessionManager) cookie(ctx *pageContext, sess *Session) *http.Cookie {
+	sess.mux.Lock()
+	defer sess.mux.Unlock()
+	if sess.renewed {
+		// the old session is removed so it cannot be reused
+		if err := sm.opts.Store.Delete(sess.data.Id); err != nil {
+			logger.WithError(err).Error("failed to delete renewed session")
+		}
+		sess.data.Id = newSessionId()
+		sess.renewed = false
+		sess.isNew = true
+	}
+	path := ctx.origin.prefix + ctx.site.prefix + "/"
+	secure := sm.opts.SecureCookie || ctx.origin.scheme == "https"
+	if sess.destroyed && sess.isEmpty() {
+		return &http.Cookie{
+			Name:     sm.opts.CookieName,
+			Path:     path,
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   secure,
+			SameSite: sm.opts.SameSite,
+		}
+	}
+	if !sess.isNew || !sess.dirty {
+		return nil
+	}
+	// the cookie is issued as the response starts, so that anything stored in the session while rendering is kept
+	sess.isNew = false
+	return &http.Cookie{
+		Name:     sm.opts.CookieName,
+		Value:    sm.sign(sess.data.Id),
+		Path:     path,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sm.opts.SameSite,
+	}
+}
+
+// save persists the session once the request is complete
+func (sm *sessionManager) save(sess *Session) {
+	sess.mux.Lock()
+	defer sess.mux.Unlock()
+	if !sess.dirty {
+		return
+	}
+	sess.dirty = false
+	if sess.isNew {
+		// the client was never given the cookie, so the session could not be used again
+		if !sess.isEmpty() {
+			logger.Warn("session was first stored in after the response started. It has not been kept")
+		}
+		return
+	}
+	if sess.renewed {
+		// the response had already started, so the client could not be given a new id.
+		if sess.destroyed {
+			if err := sm.opts.Store.Delete(sess.data.Id); err != nil {
+				logger.WithError(err).Error("failed to delete session")
+			}
+			return
+		}
+		logger.Warn("session was signed in after the response started. The session id could not be renewed")
+	}
+	if sess.destroyed && sess.isEmpty() {
+		return
+	}
+	if err := sm.opts.Store.Save(sess.data.clone()); err != nil {
+		logger.WithError(err).Error("failed to save session")
+	}
+}
+
+// sessionWriter makes sure the session cookie is added before the response headers are sent
+type sessionWriter struct {
+	http.ResponseWriter
+	commit    func()
+	committed bool
+}
+
+// headersSent returns true if the status of the response can no longer be changed
+func headersSent(w http.ResponseWriter) bool {
+	switch v := w.(type) {
+	case *sessionWriter:
+		return v.committed
+	case *bufferedWriter:
+		return v.flushed
+	}
+	return false
+}
+
+func (sw *sessionWriter) commitHeaders() {
+	if !sw.committed {
+		sw.committed = true
+		sw.commit()
+	}
+}
+
+func (sw *sessionWriter) WriteHeader(statusCode int) {
+	sw.commitHeaders()
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	sw.commitHeaders()
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *sessionWriter) Flush() {
+	sw.commitHeaders()
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sw *sessionWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hj, ok := sw.ResponseWriter.(http.Hijacker); ok {
+		return hj.Hijack()
+	}
+	return nil, nil, errors.New("hijacking is not supported")
+}
+
+// beginSession attaches the session to the context. The returned writer must be used for the response, and the returned function called
+// once the request is complete.
+func (s *Site) beginSession(ctx *pageContext, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	sm := s.sessions
+	sess := sm.load(r)
+	ctx.session = sess
+	sw := &sessionWriter{
+		ResponseWriter: w,
+	}
+	sw.commit = func() {
+		if c := sm.cookie(ctx, sess); c != nil {
+			http.SetCookie(sw.ResponseWriter, c)
+		}
+	}
+	startId := sess.Id()
+	return sw, func() {
+		sw.commitHeaders()
+		sm.save(sess)
+		if sess.destroyed || sess.Id() != startId {
+			// whatever was cached belonged to the session before it was signed in or out
+			s.sessionCaches.Invalidate(startId)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package register
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MemorySessionStore keeps sessions in memory. Sessions are lost when the process ends.
+type MemorySessionStore struct {
+	mux      sync.Mutex
+	sessions map[string]*SessionData
+}
+
+var _ SessionStore = (*MemorySessionStore)(nil)
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]*SessionData),
+	}
+}
+
+func (ms *MemorySessionStore) Load(id string) (*SessionData, error) {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	if sd, ok := ms.sessions[id]; ok {
+		return sd.clone(), nil
+	}
+	return nil, nil
+}
+
+func (ms *MemorySessionStore) Save(data *SessionData) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	ms.sessions[data.Id] = data.clone()
+	return nil
+}
+
+func (ms *MemorySessionStore) Delete(id string) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	delete(ms.sessions, id)
+	return nil
+}
+
+func (ms *MemorySessionStore) Purge(expired func(*SessionData) bool) error {
+	ms.mux.Lock()
+	defer ms.mux.Unlock()
+	for id, sd := range ms.sessions {
+		if expired(sd) {
+			delete(ms.sessions, id)
+		}
+	}
+	return nil
+}
+
+// FileSessionStore keeps each session as a json file within a directory, allowing sessions to survive a restart.
+type FileSessionStore struct {
+	mux sync.Mutex
+	dir string
+}
+
+var _ SessionStore = (*FileSessionStore)(nil)
+
+const sessionFileExt = ".session.json"
+
+// NewFileSessionStore creates a store that keeps its sessions in the given directory. The directory is created if it does not exist.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &FileSessionStore{
+		dir: dir,
+	}, nil
+}
+
+func (fs *FileSessionStore) filename(id string) (string, error) {
+	// the id ends up as part of a file path, so it needs to be strict
+	if id == "" || strings.Trim(id, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("invalid session id")
+	}
+	return filepath.Join(fs.dir, id+sessionFileExt), nil
+}
+
+func (fs *FileSessionStore) Load(id string) (*SessionData, error) {
+	fn, err := fs.filename(id)
+	if err != nil {
+		return nil, err
+	}
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sd SessionData
+	if err := json.Unmarshal(data, &sd); err != nil {
+		return nil, fmt.Errorf("corrupt session file %s: %w", fn, err)
+	}
+	return &sd, nil
+}
+
+func (fs *FileSessionStore) Save(sd *SessionData) error {
+	fn, err := fs.filename(sd.Id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sd)
+	if err != nil {
+		return err
+	}
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	// write to a temporary file first so a partially written session is never read
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+func (fs *FileSessionStore) Delete(id string) error {
+	fn, err := fs.filename(id)
+	if err != nil {
+		return err
+	}
+	fs.mux.Lock()
+	defer fs.mux.Unlock()
+	err = os.Remove(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (fs *FileSessionStore) Purge(expired func(*SessionData) bool) error {
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), sessionFileExt) {
+			continue
+		}
+		id := strings.TrimSuffix(e.Name(), sessionFileExt)
+		sd, err := fs.Load(id)
+		if err != nil || sd == nil {
+			continue
+		}
+		if expired(sd) {
+			if err := fs.Delete(id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package register
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionPage struct {
+}
+
+func (sp *sessionPage) Name() string {
+	return "session"
+}
+
+func (sp *sessionPage) QueryBehaviour(ctx PageContext, b Behaviour) Behaviour {
+	// the session is changed before anything is written so the session id can be renewed
+	return b.WithRenderHTML(false)
+}
+
+func (sp *sessionPage) Handler(ctx PageContext, w http.ResponseWriter, r *http.Request) interface{} {
+	switch r.URL.Query().Get("action") {
+	case "remember":
+		ctx.Session().Set("colour", "red")
+	case "login":
+		ctx.Session().SetUser(&User{Id: "u1", Name: "Jo"})
+		ctx.Session().Set("colour", "blue")
+	case "logout":
+		ctx.Session().Destroy()
+	}
+	colour, _ := ctx.Session().Get("colour")
+	fmt.Fprintf(w, "user:%s colour:%s", ctx.User().DisplayName(), colour)
+	return nil
+}
+
+func sessionRequest(h http.Handler, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func Test_Sessions(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+	for name, store := range map[string]SessionStore{"memory": NewMemorySessionStore(), "file": store} {
+		t.Run(name, func(t *testing.T) {
+			site := NewSite()
+			site.RegisterPage(nil, RootPageId, &sessionPage{})
+			site.SetSessionOptions(SessionOptions{Store: store})
+			h := site.Handler()
+
+			// browsing anonymously doesn't start a session
+			for i := 0; i < 2; i++ {
+				rec := sessionRequest(h, "/", nil)
+				assert.Empty(t, rec.Result().Cookies())
+				assert.Empty(t, rec.Header().Values("Set-Cookie"))
+				assert.Contains(t, rec.Body.String(), "user: colour:")
+			}
+
+			rec := sessionRequest(h, "/?action=remember", nil)
+			anonCookies := rec.Result().Cookies()
+			assert.Len(t, anonCookies, 1)
+			assert.Contains(t, rec.Body.String(), "user: colour:red")
+			// the cookie is only sent when something changes
+			rec = sessionRequest(h, "/", anonCookies)
+			assert.Empty(t, rec.Result().Cookies())
+			assert.Contains(t, rec.Body.String(), "user: colour:red")
+
+			// signing in renews the session id
+			rec = sessionRequest(h, "/?action=login", anonCookies)
+			cookies := rec.Result().Cookies()
+			assert.Len(t, cookies, 1)
+			assert.NotEqual(t, anonCookies[0].Value, cookies[0].Value)
+			assert.True(t, cookies[0].HttpOnly)
+			assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+			rec = sessionRequest(h, "/", cookies)
+			assert.Contains(t, rec.Body.String(), "user:Jo colour:blue")
+
+			// a tampered cookie is ignored
+			tampered := *cookies[0]
+			if tampered.Value[0] == '0' {
+				tampered.Value = "1" + tampered.Value[1:]
+			} else {
+				tampered.Value = "0" + tampered.Value[1:]
+			}
+			rec = sessionRequest(h, "/", []*http.Cookie{&tampered})
+			assert.Contains(t, rec.Body.String(), "user: colour:")
+
+			rec = sessionRequest(h, "/?action=logout", cookies)
+			assert.Contains(t, rec.Body.String(), "user: colour:")
+			assert.Equal(t, -1, rec.Result().Cookies()[0].MaxAge)
+			rec = sessionRequest(h, "/", cookies)
+			assert.Contains(t, rec.Body.String(), "user: colour:")
+		})
+	}
+}
+
+func Test_SessionExpiry(t *testing.T) {
+	site := NewSite()
+	site.RegisterPage(nil, RootPageId, &sessionPage{})
+	store := NewMemorySessionStore()
+	site.SetSessionOptions(SessionOptions{Store: store, IdleTimeout: time.Minute})
+	h := site.Handler()
+
+	cookies := sessionRequest(h, "/?action=login", nil).Result().Cookies()
+	id, ok := site.sessions.verify(cookies[0].Value)
+	assert.True(t, ok)
+	sd, _ := store.Load(id)
+	sd.LastSeen = time.Now().Add(-2 * time.Minute)
+	store.Save(sd)
+
+	rec := sessionRequest(h, "/", cookies)
+	assert.Contains(t, rec.Body.String(), "user: colour:")
+	sd, _ = store.Load(id)
+	assert.Nil(t, sd)
+}
@@ -1,5 +1,42 @@
 package register
 
 // User represents the simplest mechanism to identify a user in the system. GOOEY will manage the required infrastructure to ensure
+// the user is carried between requests as part of their session.
 type User struct {
+	// Unique identifier of the user
+	Id string
+	// The name to display for the user
+	Name  string
+	Email string
+	// The roles the user holds (see RequireRoles)
+	Roles []string
+	// Any additional information the sign-in mechanism wants to keep about the user
+	Attributes map[string]string
+}
+
+// DisplayName returns the best name available to show for the user
+func (u *User) DisplayName() string {
+	if u == nil {
+		return ""
+	}
+	if u.Name != "" {
+		return u.Name
+	}
+	if u.Email != "" {
+		return u.Email
+	}
+	return u.Id
+}
+
+// HasRole returns true if the user holds the given role
+func (u *User) HasRole(role string) bool {
+	if u == nil {
+		return false
+	}
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
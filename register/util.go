@@ -3,29 +3,47 @@ package register
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 )
 
-var attribOrder = []string{"rel", "href"}
+var attribOrder = []string{"charset", "name", "property", "http-equiv", "content", "rel", "href", "sizes", "type", "src"}
 
+// MapToAttributes writes the attributes for an element, with each value escaped. Urls in attributes such as href and src are checked
+// as well. A value of true writes the attribute on its own (i.e: disabled), while false and nil leave it out.
 func MapToAttributes(m map[string]interface{}) (retArr string) {
 	sb := strings.Builder{}
+	write := func(k string, v interface{}) {
+		switch b := v.(type) {
+		case nil:
+			return
+		case bool:
+			if b {
+				sb.WriteString(" " + EscapeAttribute(k))
+			}
+			return
+		}
+		sb.WriteString(fmt.Sprintf(` %s="%s"`, EscapeAttribute(k), escapeAttributeValue(k, v)))
+	}
 	alreadyLoaded := make(map[string]bool)
 	for _, a := range attribOrder {
-		if v, ok := m[a]; ok && v != nil {
-			sb.WriteString(fmt.Sprintf(`%s="%s"`, a, getInterfaceString(v)))
+		if v, ok := m[a]; ok {
+			write(a, v)
 			alreadyLoaded[a] = true
 		}
 	}
-	for k, v := range m {
-		if _, ok := alreadyLoaded[k]; !ok && v != nil {
-			sb.WriteString(fmt.Sprintf(`%s="%s"`, k, getInterfaceString(v)))
+	var rest []string
+	for k := range m {
+		if !alreadyLoaded[k] {
+			rest = append(rest, k)
 		}
 	}
-	if sb.Len() > 0 {
-		return " " + sb.String()
+	// the rest are sorted so that the same element is always written the same way
+	sort.Strings(rest)
+	for _, k := range rest {
+		write(k, m[k])
 	}
-	return ""
+	return sb.String()
 }
 
 func getInterfaceString(v interface{}) string {